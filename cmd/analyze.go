@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// caseBucket はパラメータの範囲で分けたケースの集まりです。
+type caseBucket struct {
+	label string
+	ids   []int
+}
+
+var logAnalyzeCmd = &cobra.Command{
+	Use:   "analyze <No.>",
+	Short: "break down a run by input parameters",
+	Long: `Break down a logged run by one or two input parameters.
+The cases are split into bins (quantile or fixed width), and each bin shows the count, GM, AM, failures and the ratio against the best and the last run.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		if err := analyzeRun(args[0]); err != nil {
+			errorPrint("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// paramColumn はパラメータ名のhi.Headerでの位置を返します。
+func paramColumn(name string) (int, error) {
+	for i, h := range hi.Header {
+		if h == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown parameter '%s' (%s)", name, strings.Join(hi.Header, ", "))
+}
+
+// paramValue はケースidのパラメータの値を返します。
+func paramValue(id, col int) (float64, bool) {
+	if col >= len(hi.HeaderData[id]) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(hi.HeaderData[id][col], 64)
+	return v, err == nil
+}
+
+// binCases はidsをパラメータcolの値でk個に分けます。fixedがtrueの場合は等幅、falseの場合はケース数が等しくなるように分けます。
+// 同じ値のケースは同じビンに入れ、値の種類がk以下の場合は値ごとに分けます。
+func binCases(ids []int, col int, name string, k int, fixed bool) ([]caseBucket, error) {
+	type cv struct {
+		id int
+		v  float64
+	}
+	cs := make([]cv, 0, len(ids))
+	for _, id := range ids {
+		v, ok := paramValue(id, col)
+		if !ok {
+			return nil, fmt.Errorf("parameter '%s' of case %04d is not a number", name, id)
+		}
+		cs = append(cs, cv{id, v})
+	}
+	if len(cs) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].v < cs[j].v })
+	distinct := 1
+	for i := 1; i < len(cs); i++ {
+		if cs[i].v != cs[i-1].v {
+			distinct++
+		}
+	}
+	k = max(k, 1)
+
+	// 各ケースのビン番号を決める
+	bin := make([]int, len(cs))
+	lo, hi := cs[0].v, cs[len(cs)-1].v
+	for i := range cs {
+		switch {
+		case distinct <= k:
+			if i > 0 && cs[i].v != cs[i-1].v {
+				bin[i] = bin[i-1] + 1
+			} else if i > 0 {
+				bin[i] = bin[i-1]
+			}
+		case fixed:
+			bin[i] = min(int((cs[i].v-lo)/(hi-lo)*float64(k)), k-1)
+		default:
+			bin[i] = i * k / len(cs)
+			// 同じ値は前のケースと同じビンに入れる
+			if i > 0 && cs[i].v == cs[i-1].v {
+				bin[i] = bin[i-1]
+			}
+		}
+	}
+
+	bs := make([]caseBucket, 0, k)
+	for i := 0; i < len(cs); {
+		j := i
+		for j < len(cs) && bin[j] == bin[i] {
+			j++
+		}
+		b := caseBucket{}
+		for _, c := range cs[i:j] {
+			b.ids = append(b.ids, c.id)
+		}
+		sort.Ints(b.ids)
+		if cs[i].v == cs[j-1].v {
+			b.label = fmt.Sprintf("%s=%s", name, ftoa(cs[i].v))
+		} else {
+			b.label = fmt.Sprintf("%s=%s-%s", name, ftoa(cs[i].v), ftoa(cs[j-1].v))
+		}
+		bs = append(bs, b)
+		i = j
+	}
+	return bs, nil
+}
+
+// crossBuckets は2つのパラメータのビンの組み合わせごとにケースを分けます。ケースがない組み合わせは除きます。
+func crossBuckets(a, b []caseBucket) []caseBucket {
+	inB := make(map[int]int)
+	for j, bb := range b {
+		for _, id := range bb.ids {
+			inB[id] = j
+		}
+	}
+	res := make([]caseBucket, 0)
+	for _, ba := range a {
+		groups := make([][]int, len(b))
+		for _, id := range ba.ids {
+			j := inB[id]
+			groups[j] = append(groups[j], id)
+		}
+		for j, g := range groups {
+			if len(g) > 0 {
+				res = append(res, caseBucket{label: ba.label + " " + b[j].label, ids: g})
+			}
+		}
+	}
+	return res
+}
+
+// analyzeRun はログ番号idの実行をパラメータごとに集計して表示します。
+func analyzeRun(id string) error {
+	no, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid log number: %s", id)
+	}
+	pos := -1
+	for i := range logs.idxes {
+		if logs.idxes[i] == no {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return fmt.Errorf("log not found")
+	}
+	cur := logs.vals[pos]
+	// 比較する前回の実行は対象以外で最後の完了した実行とする
+	lastPos := -1
+	for i := len(logs.runs) - 1; i >= 0; i-- {
+		if i != pos && logs.runs[i].Complete {
+			lastPos = i
+			break
+		}
+	}
+
+	names := strings.Split(opt.analyzeBy, ",")
+	if opt.analyzeBy == "" && len(hi.Header) > 0 {
+		names = []string{hi.Header[0]}
+	}
+	if len(names) == 0 || len(names) > 2 || names[0] == "" {
+		return fmt.Errorf("--by takes one or two parameters")
+	}
+	fixed := false
+	switch opt.analyzeBinning {
+	case "quantile":
+	case "fixed":
+		fixed = true
+	default:
+		return fmt.Errorf("--binning must be quantile or fixed")
+	}
+	ids := filteredIDs()
+	var buckets []caseBucket
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		col, err := paramColumn(name)
+		if err != nil {
+			return err
+		}
+		bs, err := binCases(ids, col, name, opt.analyzeBins, fixed)
+		if err != nil {
+			return err
+		}
+		if buckets == nil {
+			buckets = bs
+		} else {
+			buckets = crossBuckets(buckets, bs)
+		}
+	}
+
+	lastCap := "vs Last"
+	var last []caseScore
+	if lastPos >= 0 {
+		last = logs.vals[lastPos]
+		lastCap = fmt.Sprintf("vs %04d", logs.idxes[lastPos])
+	}
+	best := relativeBest()
+	headerStyle := lipgloss.NewStyle().Bold(true)
+	fmt.Printf("%04d %s  (%s, %s bins)\n\n", no, logs.comments[pos], strings.Join(names, " x "), opt.analyzeBinning)
+	fmt.Println(headerStyle.Render(fmt.Sprintf("%-24s %6s %10s %10s %6s %9s %9s", "Bin", "Cases", "GM", "AM", "Fail", "vs Best", lastCap)))
+	for _, b := range append(buckets, caseBucket{label: "Total", ids: ids}) {
+		sc := make([]caseScore, len(b.ids))
+		for j, i := range b.ids {
+			sc[j] = cur[i]
+		}
+		gm, am, ng := calcAverage(sc)
+		// ベストと前回に対する変化は、どちらも両方にスコアがあるケースの対数比の平均とする
+		vsBest := meanRatioText(cur, best, b.ids)
+		vsLast := "-"
+		if last != nil {
+			vsLast = meanRatioText(cur, last, b.ids)
+		}
+		line := fmt.Sprintf("%-24s %6d %10s %10s %6d %9s %9s", truncString(b.label, 24), len(b.ids), formatMean(gm), formatMean(am), ng, vsBest, vsLast)
+		if b.label == "Total" {
+			fmt.Println("")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// meanRatioText はケースidsのrefに対する対数比の平均を変化率(%)で返します。比較できるケースがない場合は「-」です。
+func meanRatioText(cur, ref []caseScore, ids []int) string {
+	s, n := 0.0, 0
+	for _, i := range ids {
+		if r, ok := logRatio(cur[i], ref[i]); ok {
+			s += r
+			n++
+		}
+	}
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f%%", ratioPercent(s/float64(n)))
+}
+
+func init() {
+	logCmd.AddCommand(logAnalyzeCmd)
+	logAnalyzeCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	logAnalyzeCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
+	logAnalyzeCmd.Flags().StringVar(&opt.analyzeBy, "by", "", "One or two parameters to break down by (e.g. N,M; default: the first parameter)")
+	logAnalyzeCmd.Flags().IntVar(&opt.analyzeBins, "bins", 4, "Number of bins per parameter")
+	logAnalyzeCmd.Flags().StringVar(&opt.analyzeBinning, "binning", "quantile", "How to split the values (quantile or fixed)")
+}
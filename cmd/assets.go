@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skratchdot/open-golang/open"
+)
+
+// hc webとhc run --webのページは、オフラインでも表示できるようにバイナリに埋め込む。
+
+//go:embed assets
+var assetFS embed.FS
+
+var (
+	paramTmpl  = template.Must(template.ParseFS(assetFS, "assets/param.html"))
+	scoresTmpl = template.Must(template.ParseFS(assetFS, "assets/scores.html"))
+	liveTmpl   = template.Must(template.ParseFS(assetFS, "assets/live.html"))
+)
+
+// DefaultWebPort はWebサーバーの既定のポートです。
+const DefaultWebPort = 8080
+
+// assetHandler は埋め込んだファイルを/assets/以下で返します。
+func assetHandler() http.Handler {
+	sub, _ := fs.Sub(assetFS, "assets")
+	return http.StripPrefix("/assets/", http.FileServer(http.FS(sub)))
+}
+
+// listenWeb は--hostと--portで指定したアドレスで待ち受けます。ポートが使われている場合は空いているポートを使います。
+// 返り値のURLはブラウザで開くためのものです。
+func listenWeb() (net.Listener, string, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(opt.webHost, strconv.Itoa(opt.webPort)))
+	if err != nil && isAddrInUse(err) {
+		warningPrint("Port %d is already in use. Using a free port instead.", opt.webPort)
+		ln, err = net.Listen("tcp", net.JoinHostPort(opt.webHost, "0"))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	host := opt.webHost
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	return ln, fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.Itoa(port))), nil
+}
+
+// openBrowser はサーバーが起動するのを少し待ってからブラウザでurlを開きます。
+func openBrowser(url string) {
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		if err := open.Start(url); err != nil {
+			warningPrint("Failed to open browser: %v", err)
+		}
+	}()
+}
+
+// serveWeb はhandlerのサーバーを起動し、ブラウザでpathを開きます。サーバーが終了するまで戻りません。
+func serveWeb(handler http.Handler, path string) {
+	ln, url, err := listenWeb()
+	if err != nil {
+		errorPrint("Failed to start the web server: %v", err)
+		return
+	}
+	fmt.Printf("Serving on %s\n", url)
+	openBrowser(url + path)
+	if err := http.Serve(ln, handler); err != nil {
+		errorPrint("Failed to serve: %v", err)
+	}
+}
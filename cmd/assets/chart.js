@@ -0,0 +1,200 @@
+// hcChart はhc webのページで使う小さなSVGのグラフです(ヒストグラム、散布図、ヒートマップ)。
+// 外部のライブラリを読み込まずにオフラインで表示するためのものです。
+var hcChart = (function () {
+    var NS = 'http://www.w3.org/2000/svg';
+    var W = 800, H = 440, M = {left: 70, right: 20, top: 40, bottom: 50};
+
+    function el(tag, attrs, parent) {
+        var e = document.createElementNS(NS, tag);
+        for (var k in attrs) e.setAttribute(k, attrs[k]);
+        if (parent) parent.appendChild(e);
+        return e;
+    }
+
+    function label(parent, x, y, s, attrs) {
+        var t = el('text', Object.assign({x: x, y: y, 'font-size': 12, 'font-family': 'sans-serif'}, attrs || {}), parent);
+        t.textContent = s;
+        return t;
+    }
+
+    function tip(e, s) {
+        el('title', {}, e).textContent = s;
+    }
+
+    function fmt(v) {
+        if (!isFinite(v)) return '';
+        var a = Math.abs(v);
+        if (a >= 1e6 || (a > 0 && a < 1e-3)) return v.toExponential(2);
+        return (Math.round(v * 1000) / 1000).toString();
+    }
+
+    // niceTicks はminからmaxまでの見やすい目盛りを返します。
+    function niceTicks(min, max, n) {
+        if (min === max) return [min];
+        var step = Math.pow(10, Math.floor(Math.log10((max - min) / n)));
+        var err = (max - min) / n / step;
+        if (err >= 7.5) step *= 10;
+        else if (err >= 3.5) step *= 5;
+        else if (err >= 1.5) step *= 2;
+        var ts = [];
+        for (var v = Math.ceil(min / step) * step; v <= max + step * 1e-9; v += step) ts.push(Math.round(v / step) * step);
+        return ts;
+    }
+
+    function scale(min, max, from, to, log) {
+        if (log) {
+            min = Math.log(min);
+            max = Math.log(max);
+        }
+        if (min === max) {
+            min -= 1;
+            max += 1;
+        }
+        return function (v) {
+            if (log) v = Math.log(v);
+            return from + (v - min) / (max - min) * (to - from);
+        };
+    }
+
+    function frame(target, title) {
+        var root = typeof target === 'string' ? document.getElementById(target) : target;
+        root.innerHTML = '';
+        var svg = el('svg', {width: W, height: H, viewBox: '0 0 ' + W + ' ' + H}, root);
+        label(svg, W / 2, 22, title || '', {'text-anchor': 'middle', 'font-size': 15, 'font-weight': 'bold'});
+        return svg;
+    }
+
+    function axes(svg, xs, ys, xTicks, yTicks, xTitle, yTitle) {
+        var g = el('g', {stroke: '#ccc'}, svg);
+        yTicks.forEach(function (t) {
+            var y = ys(t);
+            el('line', {x1: M.left, x2: W - M.right, y1: y, y2: y}, g);
+            label(svg, M.left - 6, y + 4, fmt(t), {'text-anchor': 'end'});
+        });
+        xTicks.forEach(function (t) {
+            var x = xs(t);
+            el('line', {x1: x, x2: x, y1: M.top, y2: H - M.bottom, 'stroke-dasharray': '2,3'}, g);
+            label(svg, x, H - M.bottom + 16, fmt(t), {'text-anchor': 'middle'});
+        });
+        el('rect', {x: M.left, y: M.top, width: W - M.left - M.right, height: H - M.top - M.bottom, fill: 'none', stroke: '#888'}, svg);
+        label(svg, (M.left + W - M.right) / 2, H - 12, xTitle || '', {'text-anchor': 'middle'});
+        label(svg, 16, (M.top + H - M.bottom) / 2, yTitle || '', {'text-anchor': 'middle', transform: 'rotate(-90 16 ' + (M.top + H - M.bottom) / 2 + ')'});
+    }
+
+    // histogram は値の分布を描きます。opts: {title, xTitle, yTitle, bins}
+    function histogram(target, values, opts) {
+        opts = opts || {};
+        var svg = frame(target, opts.title);
+        var vs = values.filter(isFinite);
+        if (vs.length === 0) return;
+        var min = Math.min.apply(null, vs), max = Math.max.apply(null, vs);
+        var bins = opts.bins || Math.min(40, Math.max(5, Math.ceil(Math.sqrt(vs.length))));
+        var width = (max - min) / bins || 1;
+        var counts = new Array(bins).fill(0);
+        vs.forEach(function (v) { counts[Math.min(bins - 1, Math.floor((v - min) / width))]++; });
+        var top = Math.max.apply(null, counts);
+        var xs = scale(min, min + width * bins, M.left, W - M.right);
+        var ys = scale(0, top, H - M.bottom, M.top);
+        axes(svg, xs, ys, niceTicks(min, min + width * bins, 8), niceTicks(0, top, 6), opts.xTitle || 'Value', opts.yTitle || 'Count');
+        counts.forEach(function (c, i) {
+            var x0 = xs(min + width * i), x1 = xs(min + width * (i + 1));
+            var r = el('rect', {x: x0 + 1, y: ys(c), width: Math.max(x1 - x0 - 2, 1), height: ys(0) - ys(c), fill: '#4c78a8'}, svg);
+            tip(r, fmt(min + width * i) + ' - ' + fmt(min + width * (i + 1)) + ': ' + c);
+        });
+    }
+
+    // scatter は点を描きます。traces: [{x, y, text, name, color}]、opts: {title, xTitle, yTitle, logY}
+    function scatter(target, traces, opts) {
+        opts = opts || {};
+        var svg = frame(target, opts.title);
+        var xa = [], ya = [];
+        traces.forEach(function (t) {
+            t.x.forEach(function (v, i) {
+                if (opts.logY && !(t.y[i] > 0)) return;
+                xa.push(v);
+                ya.push(t.y[i]);
+            });
+        });
+        if (xa.length === 0) return;
+        var xmin = Math.min.apply(null, xa), xmax = Math.max.apply(null, xa);
+        var ymin = Math.min.apply(null, ya), ymax = Math.max.apply(null, ya);
+        if (!opts.logY) {
+            var pad = (ymax - ymin) * 0.05 || 1;
+            ymin -= pad;
+            ymax += pad;
+        }
+        var xs = scale(xmin, xmax, M.left + 10, W - M.right - 10);
+        var ys = scale(ymin, ymax, H - M.bottom - 5, M.top + 5, opts.logY);
+        var yTicks = opts.logY ? logTicks(ymin, ymax) : niceTicks(ymin, ymax, 6);
+        axes(svg, xs, ys, niceTicks(xmin, xmax, 8), yTicks, opts.xTitle, opts.yTitle);
+        traces.forEach(function (t, k) {
+            var g = el('g', {fill: t.color || '#4c78a8', 'fill-opacity': 0.7}, svg);
+            t.x.forEach(function (v, i) {
+                if (opts.logY && !(t.y[i] > 0)) return;
+                var c = el('circle', {cx: xs(v), cy: ys(t.y[i]), r: 3.5}, g);
+                tip(c, (t.text ? t.text[i] + '\n' : '') + 'x=' + fmt(v) + '\ny=' + fmt(t.y[i]));
+            });
+            if (t.name) {
+                el('circle', {cx: W - M.right - 90, cy: M.top + 12 + k * 16, r: 4, fill: t.color || '#4c78a8'}, svg);
+                label(svg, W - M.right - 80, M.top + 16 + k * 16, t.name);
+            }
+        });
+    }
+
+    function logTicks(min, max) {
+        var ts = [];
+        var steps = [1, 2, 5];
+        for (var e = Math.floor(Math.log10(min)); e <= Math.ceil(Math.log10(max)); e++) {
+            steps.forEach(function (s) {
+                var v = s * Math.pow(10, e);
+                if (v >= min && v <= max) ts.push(v);
+            });
+        }
+        if (ts.length < 3) ts = niceTicks(min, max, 5).filter(function (v) { return v > 0; });
+        return ts;
+    }
+
+    // heatmapColor はmidを白とし、大きい方を青、小さい方を赤にします。
+    function heatmapColor(v, lo, mid, hi) {
+        if (v === null || v === undefined) return '#eee';
+        var t = v >= mid ? (hi > mid ? (v - mid) / (hi - mid) : 0) : (lo < mid ? -(mid - v) / (mid - lo) : 0);
+        t = Math.max(-1, Math.min(1, t));
+        var r, g, b;
+        if (t >= 0) {
+            r = 255 - 200 * t; g = 255 - 150 * t; b = 255 - 40 * t;
+        } else {
+            r = 255 + 40 * t; g = 255 + 190 * t; b = 255 + 190 * t;
+        }
+        return 'rgb(' + Math.round(r) + ',' + Math.round(g) + ',' + Math.round(b) + ')';
+    }
+
+    // heatmap はセルごとの値を色で描きます。zはy方向の行の配列で、値がないセルはnullです。
+    // opts: {title, xTitle, yTitle, mid, text}
+    function heatmap(target, x, y, z, opts) {
+        opts = opts || {};
+        var svg = frame(target, opts.title);
+        var mid = opts.mid === undefined ? 0 : opts.mid;
+        var all = [].concat.apply([], z).filter(function (v) { return v !== null; });
+        var lo = Math.min.apply(null, all.concat([mid])), hi = Math.max.apply(null, all.concat([mid]));
+        var left = M.left + 60, cw = (W - left - M.right) / Math.max(x.length, 1), ch = (H - M.top - M.bottom) / Math.max(y.length, 1);
+        y.forEach(function (yl, j) {
+            var yy = H - M.bottom - (j + 1) * ch;
+            label(svg, left - 6, yy + ch / 2 + 4, yl, {'text-anchor': 'end'});
+            x.forEach(function (xl, i) {
+                var v = z[j][i];
+                var r = el('rect', {x: left + i * cw, y: yy, width: cw - 1, height: ch - 1, fill: heatmapColor(v, lo, mid, hi)}, svg);
+                var s = xl + '\n' + yl + '\n' + (v === null ? 'no data' : fmt(v));
+                if (opts.text) s += '\n' + opts.text[j][i];
+                tip(r, s);
+                if (v !== null) label(svg, left + i * cw + cw / 2, yy + ch / 2 + 4, fmt(v), {'text-anchor': 'middle'});
+            });
+        });
+        x.forEach(function (xl, i) {
+            label(svg, left + i * cw + cw / 2, H - M.bottom + 16, xl, {'text-anchor': 'middle'});
+        });
+        label(svg, (left + W - M.right) / 2, H - 12, opts.xTitle || '', {'text-anchor': 'middle'});
+        label(svg, 16, (M.top + H - M.bottom) / 2, opts.yTitle || '', {'text-anchor': 'middle', transform: 'rotate(-90 16 ' + (M.top + H - M.bottom) / 2 + ')'});
+    }
+
+    return {histogram: histogram, scatter: scatter, heatmap: heatmap};
+})();
@@ -0,0 +1,388 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// bisectCmd はgoodとbadの間のコミットを二分探索し、選んだケースのGMが悪化した最初のコミットを探します。
+var bisectCmd = &cobra.Command{
+	Use:   "bisect <good> <bad>",
+	Short: "Find the first commit that made the selected cases worse",
+	Long: `Find the first commit between <good> and <bad> that made the selected cases worse.
+Each revision is checked out into a temporary git worktree, built with BuildCmd and run on the cases selected by -f or --cases.
+A revision is bad when its GM is worse than the GM of <good> by more than --threshold percent, or when more cases fail.
+Revisions that fail to build are skipped, like git bisect skip.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		if opt.bisectCases != "" {
+			ids, err := parseCaseList(opt.bisectCases)
+			if err != nil {
+				errorPrint("%v", err)
+				os.Exit(1)
+			}
+			ri.testID = ids
+		}
+		if len(ri.testID) == 0 {
+			errorPrint("No test cases selected")
+			os.Exit(1)
+		}
+		opt.quietMode = true
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := bisect(ctx, args[0], args[1], ri.testID); err != nil {
+			errorPrint("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// bisectResult は1つのリビジョンの実行結果です。
+type bisectResult struct {
+	commit   string
+	gm       float64
+	ng       int
+	scores   []caseScore
+	buildErr error
+}
+
+// parseCaseList は「3,5,10-12」の形式のケース番号の一覧を読み込みます。
+func parseCaseList(s string) ([]int, error) {
+	ids := make([]int, 0)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		lo, hi := f, f
+		if i := strings.Index(f, "-"); i > 0 {
+			lo, hi = f[:i], f[i+1:]
+		}
+		a, err1 := strconv.Atoi(lo)
+		b, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || a > b {
+			return nil, fmt.Errorf("invalid case '%s'", f)
+		}
+		if a < 0 || b >= set.TestDataNum {
+			return nil, fmt.Errorf("case '%s' is out of range (0-%d)", f, set.TestDataNum-1)
+		}
+		for i := a; i <= b; i++ {
+			ids = append(ids, i)
+		}
+	}
+	return ids, nil
+}
+
+// resolveRev はリビジョン名をコミットIDに変換します。
+func resolveRev(rev string) (string, error) {
+	o, err := git("rev-parse", "--verify", "-q", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision '%s'", rev)
+	}
+	return strings.TrimSpace(string(o)), nil
+}
+
+// commitSubject はコミットメッセージの1行目を返します。
+func commitSubject(commit string) string {
+	o, _ := git("log", "-1", "--format=%s", commit)
+	return strings.TrimSpace(string(o))
+}
+
+// bisect はgoodからbadまでのコミットを二分探索します。badの結果がgoodより悪くない場合は探索しません。
+func bisect(ctx context.Context, goodRev, badRev string, testID []int) error {
+	root, err := gitRoot()
+	if err != nil {
+		return fmt.Errorf("not a git repository")
+	}
+	good, err := resolveRev(goodRev)
+	if err != nil {
+		return err
+	}
+	bad, err := resolveRev(badRev)
+	if err != nil {
+		return err
+	}
+	o, err := git("rev-list", "--reverse", "--ancestry-path", good+".."+bad)
+	if err != nil {
+		return err
+	}
+	commits := strings.Fields(string(o))
+	if len(commits) == 0 {
+		return fmt.Errorf("%s is not an ancestor of %s", goodRev, badRev)
+	}
+	// worktreeの中でhcを実行するディレクトリに対応する場所
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, wd)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = "."
+	}
+
+	steps := 0
+	for n := len(commits); n > 1; n = (n + 1) / 2 {
+		steps++
+	}
+	fmt.Printf("Bisecting %d commits between %s and %s on %d cases (threshold %.2f%%, about %d runs)\n\n",
+		len(commits), shortSHA(good), shortSHA(bad), len(testID), opt.bisectThreshold, steps+2)
+
+	base, err := evalRevision(ctx, good, rel, testID)
+	if err != nil {
+		return err
+	}
+	if base.buildErr != nil {
+		return fmt.Errorf("the good revision could not be built: %v", base.buildErr)
+	}
+	printBisectStep("good", base, base, false)
+
+	last, err := evalRevision(ctx, bad, rel, testID)
+	if err != nil {
+		return err
+	}
+	if last.buildErr != nil {
+		return fmt.Errorf("the bad revision could not be built: %v", last.buildErr)
+	}
+	lastBad := isBisectBad(base, last)
+	printBisectStep("bad", base, last, lastBad)
+	if !lastBad {
+		fmt.Println("")
+		fmt.Printf("%s is not worse than %s on the selected cases\n", shortSHA(bad), shortSHA(good))
+		return nil
+	}
+
+	// commits[lo]はgood(lo=-1はgood自身)、commits[hi]はbad
+	lo, hi := -1, len(commits)-1
+	results := map[int]bisectResult{hi: last}
+	skipped := make(map[int]bool)
+	step := 0
+	for {
+		mid := bisectMid(lo, hi, skipped)
+		if mid < 0 {
+			break
+		}
+		step++
+		res, err := evalRevision(ctx, commits[mid], rel, testID)
+		if err != nil {
+			return err
+		}
+		if res.buildErr != nil {
+			// ビルドできないコミットはgit bisect skipと同じく判定せずに残りの範囲を探す
+			skipped[mid] = true
+			printBisectStep(fmt.Sprintf("[%d]", step), base, res, false)
+			continue
+		}
+		isBad := isBisectBad(base, res)
+		printBisectStep(fmt.Sprintf("[%d]", step), base, res, isBad)
+		if isBad {
+			hi = mid
+			results[mid] = res
+		} else {
+			lo = mid
+		}
+	}
+
+	first := commits[hi]
+	res := results[hi]
+	fmt.Println("")
+	if len(skipped) > 0 {
+		ks := make([]int, 0, len(skipped))
+		for k := range skipped {
+			ks = append(ks, k)
+		}
+		sort.Ints(ks)
+		fmt.Printf("Skipped %d commits that failed to build:\n", len(ks))
+		for _, k := range ks {
+			fmt.Printf("  %s %s\n", shortSHA(commits[k]), commitSubject(commits[k]))
+		}
+		// goodとbadの間に判定できなかったコミットが残る場合は、最初の悪化を1つに絞れない
+		if hi-lo > 1 {
+			fmt.Println("")
+			fmt.Println(lipgloss.NewStyle().Bold(true).Render("The first bad commit could be any of:"))
+			for k := lo + 1; k <= hi; k++ {
+				fmt.Printf("  %s %s\n", shortSHA(commits[k]), commitSubject(commits[k]))
+			}
+			return nil
+		}
+		fmt.Println("")
+	}
+	fmt.Println(lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("First bad commit: %s", first)))
+	fmt.Printf("  %s\n", commitSubject(first))
+	fmt.Printf("  GM %s -> %s (%+.2f%%)  Failed %d -> %d\n", formatMean(base.gm), formatMean(res.gm), gmChange(base.gm, res.gm), base.ng, res.ng)
+	printWorstCases(base.scores, res.scores, testID, 5)
+	return nil
+}
+
+// evalRevision はcommitをworktreeに取り出してビルドし、testIDのケースを実行します。
+// プログラムはworktree内でビルドしたものを使い、入力ファイルやジャッジは元のディレクトリのものを使います。
+func evalRevision(ctx context.Context, commit string, rel string, testID []int) (bisectResult, error) {
+	res := bisectResult{commit: commit}
+	dir, err := os.MkdirTemp("", "hc-bisect-")
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(dir)
+	if _, err := git("worktree", "add", "--detach", dir, commit); err != nil {
+		return res, err
+	}
+	defer git("worktree", "remove", "--force", dir)
+	sub := filepath.Join(dir, rel)
+
+	if fs := strings.Fields(cmn.BuildCmd); len(fs) > 0 {
+		cmd := exec.CommandContext(ctx, fs[0], fs[1:]...)
+		cmd.Dir = sub
+		if o, err := cmd.CombinedOutput(); err != nil {
+			if ctx.Err() != nil {
+				return res, errInterrupted
+			}
+			if opt.debugMode {
+				debugPrint("bisect: build output: %s", truncString(string(o), 1000))
+			}
+			res.buildErr = err
+			return res, nil
+		}
+	}
+
+	targetProgram := cmn.TargetProgram
+	cmn.TargetProgram = resolveInDir(targetProgram, sub)
+	defer func() { cmn.TargetProgram = targetProgram }()
+
+	ri = RuntimeInfo{testID: testID, parentCtx: ctx}
+	runtimeInit()
+	ri.enableLog = false
+	ri.enableLogStandings = false
+	workerPool()
+	if ri.interrupted {
+		return res, errInterrupted
+	}
+	res.scores = make([]caseScore, len(testID))
+	for i, id := range testID {
+		res.scores[i] = ri.score[id]
+	}
+	res.gm, _, res.ng = calcAverage(res.scores)
+	return res, nil
+}
+
+// resolveInDir はコマンドのうちdirに存在する相対パスの引数をdir内の絶対パスに置き換えます。
+func resolveInDir(command string, dir string) string {
+	fs := strings.Fields(command)
+	for i, f := range fs {
+		if filepath.IsAbs(f) || strings.HasPrefix(f, "-") {
+			continue
+		}
+		p := filepath.Join(dir, f)
+		if fileExists(p) || dirExists(p) {
+			fs[i] = p
+		}
+	}
+	return strings.Join(fs, " ")
+}
+
+// bisectMid は次に調べるコミットの添字を返します。中央がスキップしたコミットの場合はその近くで調べていないものを選び、
+// loとhiの間に調べられるコミットがない場合は-1を返します。
+func bisectMid(lo, hi int, skipped map[int]bool) int {
+	mid := (lo + hi) / 2
+	for d := 0; mid-d > lo || mid+d < hi; d++ {
+		for _, k := range []int{mid - d, mid + d} {
+			if k > lo && k < hi && !skipped[k] {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// isBisectBad はresがbaseより悪いかを返します。失敗したケースが増えた場合も悪いとします。
+func isBisectBad(base, res bisectResult) bool {
+	if res.ng > base.ng {
+		return true
+	}
+	d := gmChange(base.gm, res.gm)
+	if cmn.IsRankMin {
+		return d > opt.bisectThreshold
+	}
+	return d < -opt.bisectThreshold
+}
+
+// gmChange はGMの変化率(%)を返します。
+func gmChange(base, v float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (v/base - 1) * 100
+}
+
+func shortSHA(commit string) string {
+	return commit[:min(7, len(commit))]
+}
+
+func printBisectStep(label string, base, res bisectResult, isBad bool) {
+	verdict := lipgloss.NewStyle().Width(4).Foreground(lipgloss.Color("2")).Render("good")
+	if isBad {
+		verdict = lipgloss.NewStyle().Width(4).Foreground(lipgloss.Color("1")).Render("bad")
+	}
+	if res.buildErr != nil {
+		verdict = lipgloss.NewStyle().Width(4).Foreground(lipgloss.Color("3")).Render("skip")
+		fmt.Printf("%-5s %s  build failed            %s  %s\n", label, shortSHA(res.commit), verdict, commitSubject(res.commit))
+		return
+	}
+	fmt.Printf("%-5s %s  GM %10s %+8.2f%%  NG %-3d %s  %s\n", label, shortSHA(res.commit), formatMean(res.gm), gmChange(base.gm, res.gm), res.ng, verdict, commitSubject(res.commit))
+}
+
+// printWorstCases は最も悪化したケースをn件表示します。
+func printWorstCases(base, res []caseScore, testID []int, n int) {
+	type elem struct {
+		k     int     // testIDの添字
+		ratio float64 // スコアの変化率(%)
+		worse float64 // 悪化の度合い(大きいほど悪い)
+	}
+	es := make([]elem, 0)
+	for k := range testID {
+		if !base[k].valid() {
+			continue
+		}
+		// 失敗したケースを最も悪化したものとして扱う
+		e := elem{k: k, worse: math.Inf(1)}
+		if res[k].valid() {
+			e.ratio, _ = scoreRatio(res[k].v, base[k])
+			e.worse = -e.ratio
+			if cmn.IsRankMin {
+				e.worse = e.ratio
+			}
+		}
+		if e.worse > 0 {
+			es = append(es, e)
+		}
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].worse > es[j].worse })
+	for _, e := range es[:min(n, len(es))] {
+		if res[e.k].valid() {
+			fmt.Printf("  %04d %s -> %s (%+.2f%%)\n", testID[e.k], formatScore(base[e.k]), formatScore(res[e.k]), e.ratio)
+		} else {
+			fmt.Printf("  %04d %s -> %s\n", testID[e.k], formatScore(base[e.k]), formatScore(res[e.k]))
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(bisectCmd)
+	bisectCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	bisectCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
+	bisectCmd.Flags().StringVar(&opt.bisectCases, "cases", "", "Cases to run (e.g. 3,5,10-12)")
+	bisectCmd.Flags().Float64Var(&opt.bisectThreshold, "threshold", 1.0, "A revision is bad when its GM is worse than the good revision by more than this percentage")
+	bisectCmd.Flags().BoolVarP(&opt.debugMode, "debug", "x", false, "Enable debug output")
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BuildHashFile はソースファイルのハッシュを保存するファイル名です(logsディレクトリ直下)。
+const BuildHashFile = "build.txt"
+
+// sourceFiles は[common]のSourceFiles(空白区切りのパターン)に一致するファイルをソートして返します。
+// 「**」を含むパターンは、その前のディレクトリ以下を再帰的に探し、後ろのパターンをファイル名と比較します。
+func sourceFiles() ([]string, error) {
+	seen := make(map[string]bool)
+	files := make([]string, 0)
+	add := func(f string) {
+		f = filepath.ToSlash(filepath.Clean(f))
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	for _, pattern := range strings.Fields(cmn.SourceFiles) {
+		if i := strings.Index(pattern, "**"); i >= 0 {
+			root := filepath.Clean(pattern[:i])
+			rest := strings.TrimLeft(pattern[i+2:], "/")
+			if rest == "" {
+				rest = "*"
+			}
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				if ok, _ := filepath.Match(rest, d.Name()); ok {
+					add(p)
+				}
+				return nil
+			})
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+		}
+		for _, m := range matches {
+			if !dirExists(m) {
+				add(m)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// calcBuildHash はビルドコマンドとソースファイルのパス・内容からハッシュを計算します。
+func calcBuildHash() (string, error) {
+	files, err := sourceFiles()
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files match SourceFiles '%s'", cmn.SourceFiles)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", cmn.BuildCmd)
+	for _, f := range files {
+		fp, err := os.Open(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", f)
+		_, err = io.Copy(h, fp)
+		fp.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// targetExists はTargetProgramの実行ファイル(コマンドの最初の要素)があるかどうかを返します。
+func targetExists() bool {
+	fs := strings.Fields(cmn.TargetProgram)
+	if len(fs) == 0 {
+		return true
+	}
+	_, err := exec.LookPath(fs[0])
+	return err == nil
+}
+
+// autoBuild は実行前にBuildCmdでプログラムをビルドし、ビルドハッシュを返します。
+// SourceFilesのハッシュが前回のビルドから変わっておらず、実行ファイルもある場合はビルドを省略します。
+// ビルドに失敗した場合はコンパイラの出力を表示してfalseを返します。
+func autoBuild() (string, bool) {
+	if len(cmn.BuildCmd) == 0 || opt.noBuild {
+		return "", true
+	}
+	hashFile := fmt.Sprintf("%s/%s", logs.logRootDir, BuildHashFile)
+	hash := ""
+	if len(cmn.SourceFiles) != 0 {
+		var err error
+		hash, err = calcBuildHash()
+		if err != nil {
+			warningPrint("Failed to hash the source files: %v", err)
+		} else if !opt.rebuild && readLastLine(hashFile) == hash && targetExists() {
+			if !opt.quietMode {
+				fmt.Printf("Build skipped (sources unchanged: %s)\n", hash)
+			}
+			return hash, true
+		}
+	}
+
+	if !opt.quietMode {
+		fmt.Printf("Building: %s\n", cmn.BuildCmd)
+	}
+	args := strings.Fields(cmn.BuildCmd)
+	c := exec.Command(args[0], args[1:]...)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		os.Remove(hashFile)
+		os.Stderr.Write(out)
+		errorPrint("Build failed: %v", err)
+		return "", false
+	}
+	if opt.debugMode && len(out) != 0 {
+		debugPrint("autoBuild: %s", out)
+	}
+	if hash != "" {
+		if err := writeToFile(hashFile, []byte(hash+"\n"), false); err != nil {
+			warningPrint("Failed to write %s: %v", hashFile, err)
+		}
+	}
+	return hash, true
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// ケースごとのログの保存方法
+const (
+	CaseLogsAll      = "all"      // 全ケースを保存する
+	CaseLogsFailures = "failures" // スコアが得られなかったケースとTLEのケースだけ保存する
+	CaseLogsNone     = "none"     // 保存しない
+)
+
+// caseLogs は1ケース分のソルバーの標準エラー出力とジャッジの出力です。
+type caseLogs struct {
+	stderr string
+	judge  string
+}
+
+// caseLogsMode はケースごとのログの保存方法を返します。優先順位は --case-logs、[common] の順で、未指定の場合はallです。
+// 不明な値の警告を1回だけ出すよう、runtimeInitで1回だけ呼び出してri.caseLogsに保存します。
+func caseLogsMode() string {
+	mode := cmn.CaseLogs
+	if opt.caseLogs != "" {
+		mode = opt.caseLogs
+	}
+	switch mode {
+	case "":
+		return CaseLogsAll
+	case CaseLogsAll, CaseLogsFailures, CaseLogsNone:
+		return mode
+	}
+	warningPrint("Unknown CaseLogs value '%s' (all, failures or none). Using 'all'.", mode)
+	return CaseLogsAll
+}
+
+// saveCaseLogs はケースのログをテストセットのerr/とjudge/に保存します。
+// failuresの場合、成功したケースは以前の実行で残ったログを削除します。
+func saveCaseLogs(id string, isCompare bool, res caseResult, cl caseLogs) {
+	mode := ri.caseLogs
+	if mode == CaseLogsNone {
+		return
+	}
+	suffix := ""
+	if isCompare {
+		suffix = "_b"
+	}
+	errFile := fmt.Sprintf("%s/err/%s%s.txt", set.TestDataPath, id, suffix)
+	judgeFile := fmt.Sprintf("%s/judge/%s%s.txt", set.TestDataPath, id, suffix)
+	if mode == CaseLogsFailures && res.ok && !res.tle {
+		os.Remove(errFile)
+		os.Remove(judgeFile)
+		return
+	}
+	if err := os.MkdirAll(fmt.Sprintf("%s/err", set.TestDataPath), 0755); err != nil {
+		warningPrint("Failed to create the err directory: %v", err)
+		return
+	}
+	if err := writeToFile(errFile, []byte(cl.stderr), false); err != nil {
+		warningPrint("Failed to write %s: %v", errFile, err)
+	}
+	if len(cl.judge) == 0 {
+		// ジャッジの出力がない場合は以前の実行で残ったログを削除する
+		os.Remove(judgeFile)
+		return
+	}
+	if err := os.MkdirAll(fmt.Sprintf("%s/judge", set.TestDataPath), 0755); err != nil {
+		warningPrint("Failed to create the judge directory: %v", err)
+		return
+	}
+	if err := writeToFile(judgeFile, []byte(cl.judge), false); err != nil {
+		warningPrint("Failed to write %s: %v", judgeFile, err)
+	}
+}
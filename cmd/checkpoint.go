@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// チェックポイントファイルの形式
+//
+//	1行目: start,{開始時刻},{コメント}
+//	2行目以降: {テストID},{スコア(失敗はF)},{TLEなら1},{経過時間ms:CPU時間ms:メモリKB},{判定結果}
+
+func checkpointPath() string {
+	return fmt.Sprintf("%s/%s", logs.logDir, CheckpointCsv)
+}
+
+// startCheckpoint は新しい実行のチェックポイントファイルを作成します。
+func startCheckpoint() {
+	f := checkpointPath()
+	if fileExists(f) {
+		warningPrint("Discarding the previous checkpoint: %s", f)
+	}
+	head := fmt.Sprintf("start,%s,%s\n", stringTime(), opt.logMsg)
+	if err := writeToFile(f, []byte(head), false); err != nil {
+		warningPrint("Failed to create the checkpoint file: %v", err)
+		return
+	}
+	ri.checkpoint = true
+}
+
+// appendCheckpoint は完了したケースの結果をチェックポイントファイルに追記します。
+func appendCheckpoint(idx int, res caseResult) {
+	tle := 0
+	if res.tle {
+		tle = 1
+	}
+	sc := caseScore{st: scoreFailed}
+	if res.ok {
+		sc = okScore(res.score)
+	}
+	line := fmt.Sprintf("%04d,%s%d,%s,%s\n", idx, scoresToCsv([]caseScore{sc}), tle, strings.TrimSuffix(statsToCsv([]caseStats{res.stats}), ","), res.verdict)
+	if err := writeToFile(checkpointPath(), []byte(line), true); err != nil {
+		warningPrint("Failed to write the checkpoint: %v", err)
+	}
+}
+
+// removeCheckpoint は全ケースの実行が終わったチェックポイントファイルを削除します。
+func removeCheckpoint() {
+	if !ri.checkpoint {
+		return
+	}
+	os.Remove(checkpointPath())
+	ri.checkpoint = false
+}
+
+// resumeCheckpoint はチェックポイントファイルから完了済みのケースを復元し、未実行のケースだけを実行対象にします。
+func resumeCheckpoint() bool {
+	if !ri.enableLog || opt.loop != 1 {
+		errorPrint("--resume cannot be used with --filter or --loop")
+		return false
+	}
+	lc := readFileLines(checkpointPath())
+	if len(lc) == 0 || !strings.HasPrefix(lc[0], "start,") {
+		errorPrint("Checkpoint not found: %s", checkpointPath())
+		return false
+	}
+	head := strings.SplitN(lc[0], ",", 3)
+	if len(head) == 3 {
+		// 元の実行時のコメントを引き継ぐ
+		if opt.logMsg != "" && opt.logMsg != head[2] {
+			warningPrint("Ignoring -w \"%s\": the resumed run keeps its original comment \"%s\"", opt.logMsg, head[2])
+		}
+		opt.logMsg = head[2]
+	}
+	ri.enableLogStandings = len(opt.logMsg) > 0
+
+	cnt := 0
+	for _, line := range lc[1:] {
+		ls := strings.Split(line, ",")
+		if len(ls) != 4 && len(ls) != 5 {
+			// 書き込み途中で終了した行は無視する
+			continue
+		}
+		idx, err := strconv.Atoi(ls[0])
+		sc := parseScoreField(ls[1])
+		if err != nil || sc.st == scoreNone || idx < 0 || idx >= set.TestDataNum {
+			continue
+		}
+		ri.score[idx] = sc
+		ri.trials[idx] = []caseScore{sc}
+		ri.tle[idx] = ls[2] == "1"
+		ri.stats[idx] = parseStats(ls[3])
+		if len(ls) == 5 && isVerdict(ls[4]) {
+			ri.verdict[idx] = ls[4]
+		}
+		if !ri.done[idx] {
+			ri.done[idx] = true
+			cnt++
+		}
+	}
+	ri.testID = slices.DeleteFunc(ri.testID, func(id int) bool {
+		return ri.done[id]
+	})
+	ri.checkpoint = true
+	if !opt.quietMode {
+		fmt.Printf("Resuming from the checkpoint started at %s (%d completed, %d remaining)\n", head[1], cnt, len(ri.testID))
+	}
+	return true
+}
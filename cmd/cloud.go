@@ -11,6 +11,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -261,22 +262,48 @@ func resultReader() {
 			}
 
 			// テスト結果を処理
+			addResult := func(idx int, sc caseScore) {
+				if idx < 0 || idx >= len(ri.score) {
+					return
+				}
+				mu.Lock()
+				if sc.valid() {
+					ri.scoreSum += sc.v
+					ri.okCnt++
+				} else {
+					ri.ngCnt++
+				}
+				if ri.score[idx].st == scoreNone {
+					ri.score[idx] = sc
+				} else {
+					ri.score[idx] = updateBest(ri.score[idx], sc)
+				}
+				mu.Unlock()
+			}
+			// {ID}.txtはケースごとのジャッジの出力としてローカルと同じ方法でスコアを読み取る
+			if idx, err := strconv.Atoi(strings.TrimSuffix(path.Base(objectName), ".txt")); err == nil && strings.HasSuffix(objectName, ".txt") {
+				v, err := extractScore(string(data))
+				if err != nil {
+					fmt.Printf("Score not found in %s: %v\n", objectName, err)
+					addResult(idx, caseScore{st: scoreFailed})
+					return
+				}
+				addResult(idx, okScore(v))
+				return
+			}
 			lines := strings.Split(string(data), "\n")
 			for _, line := range lines {
-				parts := strings.Split(line, " ")
+				parts := strings.SplitN(line, " ", 2)
 				if len(parts) == 2 {
 					idx, _ := strconv.Atoi(parts[0])
-					sc, _ := strconv.Atoi(parts[1])
-					mu.Lock()
-					ri.scoreSum += sc
-					ri.score[idx].a = idx
-					ri.score[idx].b = max(ri.score[idx].b, sc)
-					if sc == 0 {
-						ri.ngCnt++
+					// 「{ID} {スコア}」の形式ではスコアが空欄か「F」の場合を失敗とする(0は有効なスコア)
+					s := strings.TrimSpace(parts[1])
+					v, err := parseScoreValue(s)
+					if s == "" || s == "F" || err != nil {
+						addResult(idx, caseScore{st: scoreFailed})
 					} else {
-						ri.okCnt++
+						addResult(idx, okScore(v))
 					}
-					mu.Unlock()
 				}
 			}
 		}(attrs.Name)
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Knetic/govaluate"
 	"github.com/charmbracelet/lipgloss"
@@ -25,107 +26,47 @@ func commonInit() {
 }
 
 func loadLogs() {
-	loadHistoryCsv()
+	loadRunStore()
 	loadResultCsv()
-
-}
-func loadResultCsv() {
-	logs.best2 = make([]int, set.TestDataNum)
-	for i := 0; i < set.TestDataNum; i++ {
-		logs.best2[i] = -1
+	// 順位表ページ用のresult.csvがなければ書き出す
+	resultCsv := fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
+	if sd.Enable && !fileExists(resultCsv) {
+		if err := exportResultCsv(resultCsv); err != nil {
+			warningPrint("Failed to write %s: %v", resultCsv, err)
+		}
 	}
-	csvFile := fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
+}
 
-	lc := readFileLines(csvFile)
-	if len(lc) <= 1 {
+// loadResultCsv は順位表の結果(自分の実行と公式の順位表データ)を読み込みます。
+func loadResultCsv() {
+	logs.best2 = make([]caseScore, set.TestDataNum)
+	logs.vals2, logs.idxes2 = nil, nil
+	logs.isBlank2 = false
+	_, lc := resultCsvRows(sd.Enable)
+	if len(lc) == 0 {
 		logs.isBlank2 = true
 		return
 	}
 
-	lc = lc[1:]
 	if len(lc) > MaxHistoryRefSize {
 		lc = lc[max(0, len(lc)-MaxHistoryRefSize):]
 	}
-	results := make([][]int, len(lc))
+	results := make([][]caseScore, len(lc))
 	for i := 0; i < len(lc); i++ {
 		ls := strings.Split(lc[i], ",")
 		t := ls[1:]
 		idx := i
 		logs.idxes2 = append(logs.idxes2, idx)
-		t2 := make([]int, set.TestDataNum)
-		for j := 0; j < set.TestDataNum; j++ {
-			v, _ := strconv.Atoi(t[j])
-			t2[j] = v
-			if logs.best2[j] <= 0 && v > 0 {
-				logs.best2[j] = v
-				continue
-			}
-			if cmn.IsRankMin {
-				if logs.best2[j] > v && v > 0 {
-					logs.best2[j] = v
-				}
-			} else {
-				if logs.best2[j] < v && v > 0 {
-					logs.best2[j] = v
-				}
-			}
+		t2 := make([]caseScore, set.TestDataNum)
+		for j := 0; j < set.TestDataNum && j < len(t); j++ {
+			t2[j] = parseResultField(t[j])
+			logs.best2[j] = updateBest(logs.best2[j], t2[j])
 		}
 		results[i] = t2
 	}
 	logs.vals2 = results
 }
 
-func loadHistoryCsv() {
-	logs.best = make([]int, set.TestDataNum)
-	logs.last = make([]int, set.TestDataNum)
-	for i := 0; i < set.TestDataNum; i++ {
-		logs.best[i] = -1
-		logs.last[i] = -1
-	}
-	historyCsv := fmt.Sprintf("%s/history.csv", logs.logDir)
-	lc := readFileLines(historyCsv)
-	if len(lc) < 1 {
-		logs.isBlank = true
-		return
-	}
-	if len(lc) > MaxHistoryRefSize {
-		lc = lc[max(0, len(lc)-MaxHistoryRefSize):]
-	}
-	results := make([][]int, len(lc))
-	for i := 0; i < len(lc); i++ {
-		ls := strings.Split(lc[i], ",")
-		t := ls[3:]
-		logs.times = append(logs.times, ls[0])
-		logs.comments = append(logs.comments, ls[2])
-		idx, _ := strconv.Atoi(ls[1])
-		logs.idxes = append(logs.idxes, idx)
-		t2 := make([]int, set.TestDataNum)
-		for j := 0; j < set.TestDataNum; j++ {
-			v, _ := strconv.Atoi(t[j])
-			t2[j] = v
-			if i == len(lc)-1 {
-				logs.last[j] = v
-			}
-			if logs.best[j] <= 0 && v > 0 {
-				logs.best[j] = v
-				continue
-			}
-			if cmn.IsRankMin {
-				if logs.best[j] > v && v > 0 {
-					logs.best[j] = v
-				}
-			} else {
-				if logs.best[j] < v && v > 0 {
-					logs.best[j] = v
-				}
-			}
-		}
-		results[i] = t2
-	}
-	logs.vals = results
-
-}
-
 func readConf() {
 	viper.SetConfigName("contest") // 設定ファイルの名前（拡張子を除く）
 	viper.SetConfigType("toml")    // 設定ファイルの形式
@@ -158,6 +99,9 @@ func readConf() {
 		case "standings":
 			mapstructure.Decode(value, &conf.Standings)
 			sd = conf.Standings
+		case "score":
+			mapstructure.Decode(value, &conf.Score)
+			scr = conf.Score
 		case "env":
 			mapstructure.Decode(value, &conf.Env)
 			env = conf.Env
@@ -188,7 +132,12 @@ func readConf() {
 }
 
 func filterEvaluate(s []string) bool {
-	if len(opt.filter) == 0 {
+	return filterMatch(opt.filter, s)
+}
+
+// filterMatch はパラメータsがフィルタexprに一致するかどうかを返します。exprが空の場合は常に一致します。
+func filterMatch(expr string, s []string) bool {
+	if len(expr) == 0 {
 		return true
 	}
 	parameters := make(map[string]interface{}, 8)
@@ -199,7 +148,7 @@ func filterEvaluate(s []string) bool {
 			parameters[hi.Header[i]] = v
 		}
 	}
-	filter, _ := govaluate.NewEvaluableExpression(opt.filter)
+	filter, _ := govaluate.NewEvaluableExpression(expr)
 	result, _ := filter.Evaluate(parameters)
 	if result == false {
 		return false
@@ -275,20 +224,16 @@ func logsInit() {
 		seedsFile := fmt.Sprintf("%s/seeds.txt", set.TestDataPath)
 		set.Seeds = readFileLines(seedsFile)
 
-		indexHtml := fmt.Sprintf("%s/index.html", logs.logRootDir)
-		ret := fileExists(indexHtml)
-		if !ret {
-			downloadFile(indexHtml, sd.IndexHtmlURL)
-		}
+		// 順位表のページ(index.html)はバイナリに埋め込んだものを使う
 		inputCsv := fmt.Sprintf("%s/input.csv", logs.logDir)
-		ret = fileExists(inputCsv)
+		ret := fileExists(inputCsv)
 		if !ret {
 			header := fmt.Sprintf("file,seed,%s\n", stringsToCsv(hi.Header))
 			writeToFile(inputCsv, []byte(header), false)
 			var line string
 			for i := 0; i < set.TestDataNum; i++ {
 				if len(set.Seeds) == 0 {
-					line = fmt.Sprintf("%04d,%s,%s\n", i, i, stringsToCsv(hi.HeaderData[i]))
+					line = fmt.Sprintf("%04d,%d,%s\n", i, i, stringsToCsv(hi.HeaderData[i]))
 				} else {
 					line = fmt.Sprintf("%04d,%s,%s\n", i, set.Seeds[i], stringsToCsv(hi.HeaderData[i]))
 				}
@@ -296,13 +241,6 @@ func logsInit() {
 				writeToFile(inputCsv, []byte(line), true)
 			}
 		}
-
-		resultCsv := fmt.Sprintf("%s/result.csv", logs.logDir)
-		ret = fileExists(resultCsv)
-		if !ret {
-			header := fmt.Sprintf("rank_min,%d,%s\n", sd.RelEval, sd.VisualizerURL)
-			writeToFile(resultCsv, []byte(header), false)
-		}
 	}
 }
 
@@ -318,11 +256,15 @@ func printLine(n int) {
 	}
 }
 func printLargeScore(n int) {
-	sort.Slice(ri.score, func(i, j int) bool {
-		return ri.score[i].b > ri.score[j].b
+	idx := make([]int, len(ri.score))
+	for i := 0; i < len(idx); i++ {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		return ri.score[idx[i]].v > ri.score[idx[j]].v
 	})
-	for i := 0; i < min(n, len(ri.score)); i++ {
-		fmt.Printf("%04d %d \n", ri.score[i].a, ri.score[i].b)
+	for i := 0; i < min(n, len(idx)); i++ {
+		fmt.Printf("%04d %s \n", idx[i], formatScore(ri.score[idx[i]]))
 	}
 	fmt.Println()
 }
@@ -360,6 +302,18 @@ func readExParam(testDataPath string, testDataNum int, fields string) ([]string,
 	}
 	return fs, ret
 }
+
+// timeLimit は1ケースあたりの制限時間を返します。優先順位は --time-limit、テストセット、[common] の順で、0は無制限を表します。
+func timeLimit() time.Duration {
+	tl := cmn.TimeLimit
+	if set.TimeLimit > 0 {
+		tl = set.TimeLimit
+	}
+	if opt.timeLimit > 0 {
+		tl = opt.timeLimit
+	}
+	return time.Duration(tl * float64(time.Second))
+}
 func buildCmd(cmd string) bool {
 	if len(cmd) == 0 {
 		return true
@@ -387,40 +341,21 @@ func UpdateConfig() {
 		}
 	}
 }
-func calcRank(sc, no int) int {
-	rank := 1
-	if len(logs.vals2) != 0 && set.IsSystemTest {
 
-		for i := 0; i < len(logs.vals2); i++ {
-			if logs.vals2[i][no] <= 0 {
-				continue
-			}
-			if cmn.IsRankMin {
-				if logs.vals2[i][no] < sc {
-					rank++
-				}
-			} else {
-				if logs.vals2[i][no] > sc {
-					rank++
-				}
-			}
+// calcRank はケースnoのスコアscが記録済みの結果の中で何位かを返します。スコアがない場合は最下位とします。
+func calcRank(sc caseScore, no int) int {
+	vals := logs.vals
+	if len(logs.vals2) != 0 && set.IsSystemTest {
+		vals = logs.vals2
+	}
+	rank := 1
+	for i := 0; i < len(vals); i++ {
+		if !vals[i][no].valid() {
+			continue
 		}
-		return rank
-	} else {
-		for i := 0; i < len(logs.vals); i++ {
-			if logs.vals[i][no] <= 0 {
-				continue
-			}
-			if cmn.IsRankMin {
-				if logs.vals[i][no] < sc {
-					rank++
-				}
-			} else {
-				if logs.vals[i][no] > sc {
-					rank++
-				}
-			}
+		if !sc.valid() || isBetter(vals[i][no].v, sc.v) {
+			rank++
 		}
-		return rank
 	}
+	return rank
 }
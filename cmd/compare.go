@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// applyCompareResult は--compareで指定したプログラム(B)の結果を記録します。
+func applyCompareResult(idx int, res caseResult, mutex *sync.Mutex) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	ri.cmpDone[idx] = true
+	if res.tle {
+		ri.cmpTle[idx] = true
+	}
+	// 複数回実行した場合は最初に失敗した試行の判定結果を残す
+	if ri.cmpVerdict[idx] == "" || ri.cmpVerdict[idx] == VerdictAC {
+		ri.cmpVerdict[idx] = res.verdict
+	}
+	if res.stats.wall > ri.cmpStats[idx].wall {
+		ri.cmpStats[idx] = res.stats
+	}
+	ri.cmpTrials[idx] = append(ri.cmpTrials[idx], trialResult(res))
+	ri.cmpScore[idx] = trialScore(ri.cmpTrials[idx])
+	ri.executed++
+	ri.bar.Add(1)
+}
+
+// headToHead は両方のプログラムの結果が揃ったケースについて、Bから見た勝ち・負け・引き分けの数と相乗平均の比(B/A)を返します。
+func headToHead() (win, lose, tie int, ratio float64) {
+	logSum := 0.0
+	cnt := 0
+	for i := 0; i < len(ri.cmpDone); i++ {
+		if !ri.done[i] || !ri.cmpDone[i] {
+			continue
+		}
+		a, b := ri.score[i], ri.cmpScore[i]
+		switch {
+		case !a.valid() && !b.valid():
+			tie++
+		case !a.valid():
+			win++
+		case !b.valid():
+			lose++
+		case a.v == b.v:
+			tie++
+		case isBetter(b.v, a.v):
+			win++
+		default:
+			lose++
+		}
+		if a.valid() && b.valid() && a.v > 0 && b.v > 0 {
+			logSum += math.Log(b.v / a.v)
+			cnt++
+		}
+	}
+	ratio = 1.0
+	if cnt > 0 {
+		ratio = math.Exp(logSum / float64(cnt))
+	}
+	return win, lose, tie, ratio
+}
+
+// headToHeadLine は比較結果を1行の文字列にします。
+func headToHeadLine() string {
+	win, lose, tie, ratio := headToHead()
+	return fmt.Sprintf("A/B  Win(B) %d  Lose(B) %d  Tie %d  GM(B/A) %.2f%%", win, lose, tie, ratio*100)
+}
+
+// printCompareLog はA・Bそれぞれの結果を表示し、ラベルを付けて2件のログとして記録します。
+func printCompareLog() {
+	h2h := headToHeadLine()
+	ri.logLabel = fmt.Sprintf("A:%s", cmn.TargetProgram)
+	printLog()
+
+	// Bの結果に差し替えてもう一度記録する
+	ri.score, ri.tle, ri.stats, ri.done, ri.trials = ri.cmpScore, ri.cmpTle, ri.cmpStats, ri.cmpDone, ri.cmpTrials
+	ri.verdict = ri.cmpVerdict
+	ri.logLabel = fmt.Sprintf("B:%s", opt.compare)
+	printLog()
+
+	if !opt.quietMode {
+		fmt.Println("")
+		fmt.Println(h2h)
+	}
+}
@@ -48,6 +48,13 @@ var configSetupCmd = &cobra.Command{
 					Default: conf.Common.BuildCmd,
 				},
 			},
+			{
+				Name: "SourceFiles",
+				Prompt: &survey.Input{
+					Message: "Enter the source file patterns to detect changes, separated by spaces(Optional):",
+					Default: conf.Common.SourceFiles,
+				},
+			},
 			{
 				Name: "TargetProgram",
 				Prompt: &survey.Input{
@@ -240,7 +247,9 @@ func setupSystemTest(contestName string) {
 	seeds := fmt.Sprintf("%s/seeds.txt", testPath)
 
 	inputCsv := fmt.Sprintf("%s/input.csv", logsPath)
-	resultCsv := fmt.Sprintf("%s/result.csv", logsPath)
+	// 公式の順位表データはstandings.csvに保存し、result.csvは自分の実行と合わせて書き出す
+	standingsCsv := fmt.Sprintf("%s/%s", logsPath, StandingsCsv)
+	resultCsv := fmt.Sprintf("%s/%s", logsPath, ResultCsv)
 	err := downloadFile(seeds, seedsURL)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to download seed file:%s\n", seedsURL)
@@ -258,9 +267,11 @@ func setupSystemTest(contestName string) {
 		fmt.Fprintf(os.Stderr, "Not Found :%s\n", inputCsvURL)
 	}
 
-	err = downloadFile(resultCsv, resultCsvURL)
+	err = downloadFile(standingsCsv, resultCsvURL)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Not found : %s\n", resultCsvURL)
+	} else if fileExists(resultCsv) {
+		os.Remove(resultCsv)
 	}
 	t := TestSet{}
 	t.TestDataNum = countLines(seeds)
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
@@ -19,6 +20,9 @@ const ContestToml = "contest.toml"
 const HistoryCsv = "history.csv"
 const RunCsv = "run.csv"
 const InputCsv = "input.csv"
+const CheckpointCsv = "checkpoint.csv"
+const RunsJsonl = "runs.jsonl"
+const StandingsCsv = "standings.csv"
 const MaxHistoryRefSize = 10000
 
 var confPath string
@@ -29,21 +33,36 @@ type Config struct {
 	Standings Standings          `toml:"standings"`
 	Cloud     Cloud              `toml:"cloud"`
 	Env       Env                `toml:"env"`
+	Score     Score              `toml:"score"`
 }
 
 type Common struct {
-	ContestName   string `toml:"ContestName"`
-	TargetProgram string `toml:"TargetProgram"`
-	JudgeProgram  string `toml:"JudgeProgram"`
-	GenProgram    string `toml:"GenProgram"`
-	BaseDir       string `toml:"BaseDir"`
-	BuildCmd      string `toml:"BuildCmd"`
-	InputFields   string `toml:"InputFields"`
-	IsInteractive bool   `toml:"IsInteractive"`
-	Workers       int    `toml:"Workers"`
-	DefaultSet    string `toml:"DefaultSet"`
-	IsRankMin     bool   `toml:"IsRankMin"`
-	ScoreLine     string `toml:"ScoreLine"`
+	ContestName       string  `toml:"ContestName"`
+	TargetProgram     string  `toml:"TargetProgram"`
+	JudgeProgram      string  `toml:"JudgeProgram"`
+	GenProgram        string  `toml:"GenProgram"`
+	BaseDir           string  `toml:"BaseDir"`
+	BuildCmd          string  `toml:"BuildCmd"`
+	InputFields       string  `toml:"InputFields"`
+	IsInteractive     bool    `toml:"IsInteractive"`
+	Workers           int     `toml:"Workers"`
+	DefaultSet        string  `toml:"DefaultSet"`
+	IsRankMin         bool    `toml:"IsRankMin"`
+	ScoreLine         string  `toml:"ScoreLine"`
+	TimeLimit         float64 `toml:"TimeLimit"`
+	NativeInteractive bool    `toml:"NativeInteractive"`
+	CaseLogs          string  `toml:"CaseLogs"`
+	SourceFiles       string  `toml:"SourceFiles"`
+	MemoryLimit       int     `toml:"MemoryLimit"`
+}
+
+// Score はスコアの読み取り方の設定です。
+type Score struct {
+	Source    string `toml:"Source"`    // judge, stdout, stderr, file, exitcode
+	File      string `toml:"File"`      // Sourceがfileの場合のパス({id}, {set}, {in}, {out}を置換)
+	Extractor string `toml:"Extractor"` // line, regex, json
+	Pattern   string `toml:"Pattern"`   // lineの場合は行の先頭、regexの場合は正規表現、jsonの場合はJSONPath
+	WAPattern string `toml:"WAPattern"` // ジャッジの出力がこの正規表現に一致した場合はWAとする
 }
 
 type TestSet struct {
@@ -53,11 +72,12 @@ type TestSet struct {
 	ExFields     string   `toml:"ExFields"`
 	Seeds        []string `toml:"-"`
 	IsSystemTest bool     `toml:"IsSystemTest"`
+	TimeLimit    float64  `toml:"TimeLimit"`
 }
 
 type Standings struct {
 	Enable        bool   `toml:"Enable"`
-	IndexHtmlURL  string `toml:"IndexHtmlURL"`
+	IndexHtmlURL  string `toml:"IndexHtmlURL"` // 使用しない(順位表のページはバイナリに埋め込んでいる)
 	VisualizerURL string `toml:"VisualizerURL"`
 	RelEval       int    `toml:"RelEval"`
 }
@@ -85,18 +105,41 @@ type HeaderInfo struct {
 	HeaderData [][]string
 }
 type Options struct {
-	setName       string
-	filter        string
-	loop          int
-	target        int
-	logMsg        string
-	asc           bool
-	order         string
-	linesLimit    int
-	quietMode     bool
-	debugMode     bool // デバッグ出力用フラグ
-	testSeedBegin int
-	testCount     int
+	setName         string
+	filter          string
+	loop            int
+	target          int
+	logMsg          string
+	asc             bool
+	order           string
+	linesLimit      int
+	quietMode       bool
+	debugMode       bool // デバッグ出力用フラグ
+	testSeedBegin   int
+	testCount       int
+	timeLimit       float64
+	savePartial     bool
+	resume          bool
+	compare         string
+	caseLogs        string
+	noBuild         bool
+	rebuild         bool
+	watchInterval   int
+	memoryLimit     int
+	verdict         string
+	exportPath      string
+	worktreeDir     string
+	bisectCases     string
+	bisectThreshold float64
+	stopIfWorse     float64
+	stopRef         string
+	compact         bool
+	analyzeBy       string
+	analyzeBins     int
+	analyzeBinning  string
+	web             bool
+	webPort         int
+	webHost         string
 }
 type SetupOptions struct {
 	setName       string
@@ -107,18 +150,24 @@ type SetupOptions struct {
 type RuntimeInfo struct {
 	caption            []string
 	testID             []int
-	score              []pair
+	score              []caseScore
 	failedTask         []string
+	tleTask            []string
+	tle                []bool
+	stats              []caseStats
 	lastDist           []int
 	bestDist           []int
 	incLast            []scoreElem
 	incBest            []scoreElem
 	decLast            []scoreElem
 	decBest            []scoreElem
-	scoreSum           int
+	scoreSum           float64
 	scoreLogSum        float64
+	scoreCnt           int
+	scoreLogCnt        int
 	okCnt              int
 	ngCnt              int
+	tleCnt             int
 	ng                 []int
 	vsBest             int
 	vsLast             int
@@ -128,19 +177,46 @@ type RuntimeInfo struct {
 	enableLog          bool
 	enableLogStandings bool
 	lastDisplayTime    time.Time
+	ctx                context.Context
+	done               []bool
+	interrupted        bool
+	checkpoint         bool
+	cmpScore           []caseScore
+	cmpTle             []bool
+	cmpStats           []caseStats
+	cmpDone            []bool
+	cmpVerdict         []string
+	trials             [][]caseScore
+	cmpTrials          [][]caseScore
+	logLabel           string
+	buildHash          string
+	git                *gitState
+	sched              *schedule
+	cancel             context.CancelFunc
+	stopped            *stopStat // --stop-if-worseで打ち切った場合の途中経過
+	parentCtx          context.Context
+	live               *liveHub // hc run --webで進捗を送る先
+	verdict            []string
+	caseLogs           string // ケースごとのログの保存方法(caseLogsModeで決めた値)
 }
 type Logs struct {
 	logRootDir      string
 	logDir          string
-	last            []int
-	best            []int
-	vals            [][]int
+	last            []caseScore
+	best            []caseScore
+	vals            [][]caseScore
 	idxes           []int
 	isBlank         bool
 	times           []string
 	comments        []string
-	best2           []int
-	vals2           [][]int
+	stats           map[int][]caseStats
+	trials          map[int][]trialStat
+	builds          map[int]string
+	verdicts        map[int][]string
+	runs            []runRecord // ログ番号のある実行(古い順)
+	nextNo          int
+	best2           []caseScore
+	vals2           [][]caseScore
 	idxes2          []int
 	isBlank2        bool
 	lastDisplayTime time.Time
@@ -157,15 +233,59 @@ var sd Standings
 var jobs Cloud
 var hi HeaderInfo
 var env Env
+var scr Score
 var previousDirectory string
 
-type pair struct{ a, b int }
+type scoreState int8
+
+const (
+	scoreNone   scoreState = iota // 未実行(記録なし)
+	scoreOK                       // スコアが得られた
+	scoreFailed                   // 実行したがスコアが得られなかった
+)
+
+// caseScore は1ケースのスコアです。スコアの値とは別に、スコアが得られたかどうかを持ちます。
+type caseScore struct {
+	v  float64
+	st scoreState
+}
+
+// caseStats は1ケース分の実行時間とメモリ使用量です。
+type caseStats struct {
+	wall   time.Duration
+	cpu    time.Duration
+	maxRSS int64 // KB
+	// NativeInteractiveの場合のジャッジ側の応答待ち時間とCPU時間
+	judgeWall time.Duration
+	judgeCpu  time.Duration
+}
+
+// trialStat は--loopで複数回実行したケースのスコアの統計です。
+type trialStat struct {
+	mean float64
+	sd   float64
+	min  float64
+	max  float64
+	n    int // 有効なスコアが得られた試行数
+}
+
+// caseResult は1ケース分の実行結果です。
+type caseResult struct {
+	score float64
+	ok    bool
+	tle   bool
+	stats caseStats
+	// verdict はケースの判定結果(AC, WA, RE, TLE, MLE, NS)です。
+	verdict string
+	// canceled は中断により結果が得られなかったことを表します。
+	canceled bool
+}
 
 type scoreElem struct {
 	id       string
 	ratio    float64
-	newScore int
-	oldScore int
+	newScore float64
+	oldScore float64
 }
 
 var configTemplate = `
@@ -173,7 +293,9 @@ var configTemplate = `
 ContestName = "ContestName"
 BaseDir  = "."
 BuildCmd = ""
+SourceFiles = ""
 IsInteractive = true
+NativeInteractive = false
 TargetProgram = ""
 JudgeProgram = ""
 InputFields = ""
@@ -181,9 +303,17 @@ Workers = 5
 DefaultSet = ""
 IsRankMin = true
 ScoreLine = "Score ="
+TimeLimit = 0
+MemoryLimit = 0
+CaseLogs = "all"
+[score]
+Source = "judge"
+File = ""
+Extractor = "line"
+Pattern = ""
+WAPattern = ""
 [standings]
 Enable = true
-IndexHtmlURL = "https://img.atcoder.jp/ahc_standings/index.html"
 VisualizerURL =""
 RelEval = 100000000
 [cloud]
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+)
+
+// 途中で打ち切る判定(--stop-if-worse)
+//
+// 終わったケースについて基準(best/last)とのスコアの対数比を求め、その平均の信頼区間の上限が
+// -pct%を下回ったら、最後まで実行しても基準より悪いとみなして打ち切る。
+// 実行中に何度も判定するため、信頼区間は通常の95%より広くとる。
+
+const (
+	stopMinCases = 30   // 判定に必要な最低ケース数
+	stopZ        = 3.0  // 信頼区間の幅(標準誤差の何倍か)
+	stopClip     = 10.0 // 1ケースあたりの比は1/10〜10倍に制限する(失敗したケースは1/10倍とする)
+)
+
+// stopStat は基準に対する途中経過です。
+type stopStat struct {
+	n     int
+	mean  float64 // 対数比の平均(正なら基準より良い)
+	upper float64 // 平均の信頼区間の上限
+}
+
+// stopReference は--stop-refで指定した基準のスコアと名前を返します。
+func stopReference() ([]caseScore, string) {
+	if opt.stopRef == "last" {
+		return logs.last, "last"
+	}
+	return logs.best, "best"
+}
+
+// pairedLogRatio はケースごとのスコアの対数比を返します。IsRankMinの場合も正の値が良い方向です。
+// 比較できない場合はfalseを返します。
+func pairedLogRatio(cur, ref caseScore) (float64, bool) {
+	if !ref.valid() || ref.v <= 0 {
+		return 0, false
+	}
+	limit := math.Log(stopClip)
+	if !cur.valid() {
+		return -limit, true
+	}
+	if cur.v <= 0 {
+		return 0, false
+	}
+	r := math.Log(cur.v / ref.v)
+	if cmn.IsRankMin {
+		r = -r
+	}
+	return max(-limit, min(limit, r)), true
+}
+
+// calcStopStat は終わったケースの基準に対する対数比の平均と信頼区間の上限を求めます。
+func calcStopStat(ref []caseScore) stopStat {
+	var st stopStat
+	rs := make([]float64, 0)
+	for i := 0; i < len(ri.done); i++ {
+		if !ri.done[i] {
+			continue
+		}
+		if r, ok := pairedLogRatio(ri.score[i], ref[i]); ok {
+			rs = append(rs, r)
+		}
+	}
+	st.n = len(rs)
+	if st.n < 2 {
+		return st
+	}
+	for _, r := range rs {
+		st.mean += r
+	}
+	st.mean /= float64(st.n)
+	v := 0.0
+	for _, r := range rs {
+		v += (r - st.mean) * (r - st.mean)
+	}
+	se := math.Sqrt(v/float64(st.n-1)) / math.Sqrt(float64(st.n))
+	st.upper = st.mean + stopZ*se
+	return st
+}
+
+// checkStopIfWorse は途中経過が基準より明らかに悪ければ実行を打ち切ります。ロックした状態で呼び出してください。
+func checkStopIfWorse() {
+	if opt.stopIfWorse <= 0 || ri.stopped != nil || ri.cancel == nil {
+		return
+	}
+	ref, _ := stopReference()
+	st := calcStopStat(ref)
+	if st.n < stopMinCases {
+		return
+	}
+	if st.upper < math.Log(1-opt.stopIfWorse/100) {
+		ri.stopped = &st
+		ri.cancel()
+	}
+}
+
+// stopReport は打ち切った理由を返します。
+func stopReport() string {
+	_, name := stopReference()
+	st := ri.stopped
+	return fmt.Sprintf("Stopped early after %d/%d cases: %.2f%% worse than %s (upper bound %+.2f%%, threshold -%.2f%%)",
+		countCompleted(), len(ri.testID), -(math.Exp(st.mean)-1)*100, name, (math.Exp(st.upper)-1)*100, opt.stopIfWorse)
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DiffDir は未コミットの変更(差分)を保存するディレクトリ名です(logs/{セット名}の下)。
+const DiffDir = "diffs"
+
+// maxUntrackedSize より大きい未追跡ファイルは差分に含めません。
+const maxUntrackedSize = 1 << 20
+
+// gitRecord は実行時のソースの状態です。
+type gitRecord struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch,omitempty"` // detached HEADの場合はなし
+	Dirty  bool   `json:"dirty,omitempty"`  // 未コミットの変更があったか
+	Diff   string `json:"diff,omitempty"`   // 差分ファイル(logs/{セット名}からの相対パス)
+}
+
+// gitState は実行開始時のソースの状態と未コミットの変更です。
+type gitState struct {
+	rec  gitRecord
+	diff []byte
+}
+
+// git はカレントディレクトリでgitコマンドを実行し、標準出力を返します。
+func git(args ...string) ([]byte, error) {
+	return gitIn("", args...)
+}
+
+func gitIn(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	o, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return o, err
+}
+
+// gitRoot はリポジトリのルートディレクトリを返します。gitのリポジトリでない場合はエラーです。
+func gitRoot() (string, error) {
+	o, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(o)), nil
+}
+
+// gitPathspec は差分の対象です。hcが書き込むログとテストデータのディレクトリは除きます。
+func gitPathspec() []string {
+	ps := []string{":(top)", fmt.Sprintf(":(exclude)%s", logs.logRootDir)}
+	for _, s := range conf.TestSets {
+		if s.TestDataPath != "" {
+			ps = append(ps, fmt.Sprintf(":(exclude)%s", s.TestDataPath))
+		}
+	}
+	return ps
+}
+
+// currentGitState は現在のコミット、ブランチ、未コミットの変更を返します。gitのリポジトリでない場合はnilです。
+// 未追跡のファイルは、テキストで1MB以下のものだけを差分に含めます。
+func currentGitState() *gitState {
+	root, err := gitRoot()
+	if err != nil {
+		return nil
+	}
+	o, err := git("rev-parse", "HEAD")
+	if err != nil {
+		// コミットがまだない
+		return nil
+	}
+	gs := &gitState{rec: gitRecord{Commit: strings.TrimSpace(string(o))}}
+	if o, err = git("symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		gs.rec.Branch = strings.TrimSpace(string(o))
+	}
+
+	ps := gitPathspec()
+	diff, err := git(append([]string{"diff", "HEAD", "--binary", "--"}, ps...)...)
+	if err != nil {
+		warningPrint("Failed to get the git diff: %v", err)
+	}
+	o, _ = git(append([]string{"ls-files", "--others", "--exclude-standard", "--full-name", "-z", "--"}, ps...)...)
+	for _, f := range strings.Split(string(o), "\x00") {
+		if f == "" || !isSmallTextFile(filepath.Join(root, f)) {
+			continue
+		}
+		// --no-indexは差分があると終了コード1を返すため、出力だけを使う
+		d, _ := gitIn(root, "diff", "--no-index", "--", os.DevNull, f)
+		diff = append(diff, d...)
+	}
+	if len(diff) > 0 {
+		gs.rec.Dirty = true
+		gs.diff = diff
+	}
+	return gs
+}
+
+// isSmallTextFile はファイルがmaxUntrackedSize以下で、先頭にNUL文字を含まないかを返します。
+func isSmallTextFile(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > maxUntrackedSize {
+		return false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return bytes.IndexByte(b[:min(len(b), 8000)], 0) < 0
+}
+
+// saveGitState はログ番号noの実行のソースの状態を返し、未コミットの変更があれば差分ファイルに保存します。
+func saveGitState(gs *gitState, no int) *gitRecord {
+	if gs == nil {
+		return nil
+	}
+	rec := gs.rec
+	if rec.Dirty {
+		rec.Diff = fmt.Sprintf("%s/%04d.diff", DiffDir, no)
+		createDirIfNotExist(fmt.Sprintf("%s/%s", logs.logDir, DiffDir))
+		if err := writeToFile(fmt.Sprintf("%s/%s", logs.logDir, rec.Diff), gs.diff, false); err != nil {
+			warningPrint("Failed to save the git diff: %v", err)
+			rec.Diff = ""
+		}
+	}
+	return &rec
+}
+
+// shortCommit は「a1b2c3d」の形式の短いコミットIDを返します。未コミットの変更があった場合は「*」を付けます。
+func (g *gitRecord) shortCommit() string {
+	if g == nil {
+		return "-"
+	}
+	s := g.Commit
+	if len(s) > 7 {
+		s = s[:7]
+	}
+	if g.Dirty {
+		s += "*"
+	}
+	return s
+}
+
+// checkoutRun はログ番号noの実行のソースをgitのworktreeとしてdirに復元します。
+func checkoutRun(no int, dir string) error {
+	rec := findRun(no)
+	if rec == nil {
+		return fmt.Errorf("log not found")
+	}
+	if rec.Git == nil {
+		return fmt.Errorf("no git information recorded for this log")
+	}
+	root, err := gitRoot()
+	if err != nil {
+		return fmt.Errorf("not a git repository")
+	}
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(root), fmt.Sprintf("%s-%04d", filepath.Base(root), no))
+	}
+	if fileExists(dir) || dirExists(dir) {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	// 差分のパスはworktreeから参照するため絶対パスにしておく
+	var diffPath string
+	if rec.Git.Dirty {
+		if rec.Git.Diff == "" {
+			return fmt.Errorf("the diff of this log was not saved")
+		}
+		diffPath, err = filepath.Abs(fmt.Sprintf("%s/%s", logs.logDir, rec.Git.Diff))
+		if err != nil {
+			return err
+		}
+		if !fileExists(diffPath) {
+			return fmt.Errorf("diff file not found: %s", diffPath)
+		}
+	}
+	if _, err := git("worktree", "add", "--detach", dir, rec.Git.Commit); err != nil {
+		return err
+	}
+	if diffPath != "" {
+		if _, err := gitIn(dir, "apply", "--binary", diffPath); err != nil {
+			return fmt.Errorf("the worktree was created at %s but the diff could not be applied: %v", dir, err)
+		}
+	}
+	fmt.Printf("Restored %04d (%s", no, strings.TrimSuffix(rec.Git.shortCommit(), "*"))
+	if rec.Git.Branch != "" {
+		fmt.Printf(" on %s", rec.Git.Branch)
+	}
+	if rec.Git.Dirty {
+		fmt.Printf(", with uncommitted changes")
+	}
+	fmt.Printf(") to %s\n", dir)
+	// hcを実行するディレクトリがリポジトリのサブディレクトリであれば、worktree側の対応するディレクトリを示す
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(root, wd); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			fmt.Printf("Run hc in %s\n", filepath.Join(dir, rel))
+		}
+	}
+	return nil
+}
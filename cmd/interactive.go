@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// トランスクリプトファイルの形式
+//
+//	{開始からの経過時間ms} > {ソルバーからジャッジへの1行}
+//	{開始からの経過時間ms} < {ジャッジからソルバーへの1行}
+//	# solver={ソルバーの時間ms} judge={ジャッジの応答待ち時間ms}
+
+// transcript はジャッジとソルバーのやり取りを記録し、応答待ちの時間をジャッジ側の時間として集計します。
+type transcript struct {
+	mu        sync.Mutex
+	w         *bufio.Writer
+	start     time.Time
+	pending   bool
+	queryAt   time.Duration
+	judgeWall time.Duration
+}
+
+// record は1行分のやり取りを記録します。dirは'>'(ソルバー→ジャッジ)または'<'(ジャッジ→ソルバー)です。
+func (t *transcript) record(dir byte, line string) {
+	now := time.Since(t.start)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// ソルバーの出力からジャッジが応答するまでをジャッジの時間とする
+	if dir == '>' && !t.pending {
+		t.pending = true
+		t.queryAt = now
+	} else if dir == '<' && t.pending {
+		t.judgeWall += now - t.queryAt
+		t.pending = false
+	}
+	if t.w != nil {
+		fmt.Fprintf(t.w, "%10.3f %c %s\n", float64(now.Microseconds())/1000, dir, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// pumpLines はsrcから読んだ行をdstへ転送しながらトランスクリプトに記録します。
+func pumpLines(src io.Reader, dst io.WriteCloser, dir byte, tr *transcript) {
+	r := bufio.NewReader(src)
+	alive := true
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			tr.record(dir, line)
+			if alive {
+				if _, werr := io.WriteString(dst, line); werr != nil {
+					// 相手が終了していても、送り元が詰まらないよう読み続ける
+					alive = false
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	dst.Close()
+}
+
+// executeInteractive はジャッジとソルバーを別々に起動し、互いの標準入出力をつないで実行します。
+// ジャッジには入力ファイルのパスを引数で渡し、スコアはジャッジの標準エラー出力から読み取ります。
+// judgeStderrとsolverStderrはそれぞれの標準エラー出力です。execErrはジャッジを優先した終了状態で、solverExitはソルバー単独の終了状態です。
+func executeInteractive(filePath string, judgeCmd []string, solverCmd []string, transcriptPath string, displayStderr bool, timeLimit time.Duration, env []string) (judgeStderr string, solverStderr string, stats caseStats, execErr error, solverExit error) {
+	if opt.debugMode {
+		debugPrint("executeInteractive: file path: %s", filePath)
+		debugPrint("executeInteractive: judge: %v solver: %v", judgeCmd, solverCmd)
+	}
+	if !fileExists(filePath) {
+		return "", "", stats, fmt.Errorf("file not found: %s", filePath), nil
+	}
+	judge := exec.Command(judgeCmd[0], append(append([]string{}, judgeCmd[1:]...), filePath)...)
+	solver := exec.Command(solverCmd[0], solverCmd[1:]...)
+	if len(env) != 0 {
+		judge.Env = append(os.Environ(), env...)
+		solver.Env = judge.Env
+	}
+
+	var jerrb, serrb bytes.Buffer
+	judge.Stderr = &jerrb
+	if displayStderr {
+		solver.Stderr = os.Stderr
+	} else {
+		solver.Stderr = &serrb
+	}
+	// 標準出力はio.Pipe経由で受け取り、Waitの後に閉じて転送側に終了を伝える
+	jr, jw := io.Pipe()
+	sr, sw := io.Pipe()
+	judge.Stdout = jw
+	solver.Stdout = sw
+	jin, err := judge.StdinPipe()
+	if err != nil {
+		return "", "", stats, err, nil
+	}
+	sin, err := solver.StdinPipe()
+	if err != nil {
+		return "", "", stats, err, nil
+	}
+	// 転送先が読み込み中の間に出力が打ち切られないよう、WaitDelayは設定しない
+	setProcessGroup(judge)
+	setProcessGroup(solver)
+
+	tr := &transcript{}
+	var tf *os.File
+	if transcriptPath != "" {
+		os.MkdirAll(filepath.Dir(transcriptPath), 0755)
+		tf, err = os.Create(transcriptPath)
+		if err != nil {
+			warningPrint("Failed to create the transcript file: %v", err)
+		} else {
+			defer tf.Close()
+			tr.w = bufio.NewWriter(tf)
+		}
+	}
+
+	tr.start = time.Now()
+	if err = judge.Start(); err != nil {
+		return "", "", stats, err, nil
+	}
+	if err = solver.Start(); err != nil {
+		killProcessGroup(judge)
+		judge.Wait()
+		return "", "", stats, err, err
+	}
+
+	var pumps sync.WaitGroup
+	pumps.Add(2)
+	go func() {
+		pumpLines(jr, sin, '<', tr)
+		pumps.Done()
+	}()
+	go func() {
+		pumpLines(sr, jin, '>', tr)
+		pumps.Done()
+	}()
+
+	var judgeWaitErr, solverWaitErr error
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			judgeWaitErr = judge.Wait()
+			jw.Close()
+			wg.Done()
+		}()
+		go func() {
+			solverWaitErr = solver.Wait()
+			sw.Close()
+			wg.Done()
+		}()
+		wg.Wait()
+		close(done)
+	}()
+
+	var timeout <-chan time.Time
+	if timeLimit > 0 {
+		timer := time.NewTimer(timeLimit)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-done:
+	case <-timeout:
+		killProcessGroup(judge)
+		killProcessGroup(solver)
+		<-done
+		execErr = errTimeLimitExceeded
+	case <-runContext().Done():
+		// Ctrl-Cなどで中断された場合は両方のプロセスを終了させる
+		killProcessGroup(judge)
+		killProcessGroup(solver)
+		<-done
+		execErr = errInterrupted
+	}
+	pumps.Wait()
+	total := time.Since(tr.start)
+
+	// ソルバーの時間は全体からジャッジの応答待ちを除いたもの
+	stats = processStats(solver.ProcessState, total-tr.judgeWall)
+	stats.judgeWall = tr.judgeWall
+	if judge.ProcessState != nil {
+		stats.judgeCpu = judge.ProcessState.UserTime() + judge.ProcessState.SystemTime()
+	}
+	if tr.w != nil {
+		fmt.Fprintf(tr.w, "# solver=%dms judge=%dms\n", stats.wall.Milliseconds(), stats.judgeWall.Milliseconds())
+		tr.w.Flush()
+	}
+	solverExit = solverWaitErr
+	if execErr != nil {
+		solverExit = execErr
+	}
+	if execErr == nil {
+		execErr = judgeWaitErr
+	}
+	if execErr == nil {
+		execErr = solverWaitErr
+	}
+	if opt.debugMode && execErr != nil {
+		debugPrint("executeInteractive: %v", execErr)
+	}
+	return jerrb.String(), serrb.String(), stats, execErr, solverExit
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 実行中の進捗をブラウザに表示する(hc run --web)
+//
+// ケースの開始と終了のたびに進捗の状態をまとめてJSONにし、/eventsに接続しているページへ
+// Server-Sent Eventsで送る。ページは受け取った状態で全体を描き直す。
+
+// liveElem はスコアが増減したケースです。
+type liveElem struct {
+	ID    string  `json:"id"`
+	Old   float64 `json:"old"`
+	New   float64 `json:"new"`
+	Ratio float64 `json:"ratio"`
+}
+
+// liveCase は終わったケースです。
+type liveCase struct {
+	ID      int      `json:"id"`
+	Score   *float64 `json:"score"`
+	Verdict string   `json:"verdict"`
+	Wall    int64    `json:"wall"` // ms
+	Worker  int      `json:"worker"`
+	Compare bool     `json:"compare"`
+}
+
+// liveState はページに送る進捗の状態です。
+type liveState struct {
+	Title    string     `json:"title"`
+	Comment  string     `json:"comment"`
+	Total    int        `json:"total"`
+	Done     int        `json:"done"`
+	ETA      string     `json:"eta"`
+	Elapsed  string     `json:"elapsed"`
+	Running  []string   `json:"running"` // ワーカーごとの実行中のケース(空きは"")
+	GM       float64    `json:"gm"`
+	AM       float64    `json:"am"`
+	OK       int        `json:"ok"`
+	Failed   []string   `json:"failed"`
+	TLE      []string   `json:"tle"`
+	LastDist []int      `json:"lastDist"`
+	BestDist []int      `json:"bestDist"`
+	DecLast  []liveElem `json:"decLast"`
+	DecBest  []liveElem `json:"decBest"`
+	IncLast  []liveElem `json:"incLast"`
+	IncBest  []liveElem `json:"incBest"`
+	HeadHead string     `json:"headToHead"`
+	Case     *liveCase  `json:"case"` // 直前に終わったケース
+	Finished bool       `json:"finished"`
+	Status   string     `json:"status"`
+}
+
+// liveHub は接続しているページへの配信を管理します。
+type liveHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	latest  []byte
+	start   time.Time
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{clients: make(map[chan []byte]struct{}), start: time.Now()}
+}
+
+// subscribe は新しいページの接続を登録し、最新の状態を最初に送ります。
+func (h *liveHub) subscribe() chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan []byte, 64)
+	if h.latest != nil {
+		ch <- h.latest
+	}
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.clients[ch] = struct{}{}
+	return ch
+}
+
+func (h *liveHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[ch]; ok {
+		delete(h.clients, ch)
+		close(ch)
+	}
+}
+
+// publish は状態を全てのページに送ります。受け取りが遅れているページには送らずに読み飛ばします。
+func (h *liveHub) publish(msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latest = msg
+	for ch := range h.clients {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// close は配信を終え、送りかけの状態がページに届くのを少し待ちます。
+func (h *liveHub) close() {
+	h.mu.Lock()
+	h.closed = true
+	for ch := range h.clients {
+		delete(h.clients, ch)
+		close(ch)
+	}
+	h.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+	}
+}
+
+// liveElems はスコアの増減の一覧を送信用に変換します。
+func liveElems(es []scoreElem) []liveElem {
+	r := make([]liveElem, 0, len(es))
+	for _, e := range es {
+		r = append(r, liveElem{ID: e.id, Old: e.oldScore, New: e.newScore, Ratio: e.ratio})
+	}
+	return r
+}
+
+// newLiveCase はワーカーworkerで終わったケースidの結果を送信用に変換します。
+func newLiveCase(id, worker int, res caseResult, compare bool) *liveCase {
+	c := &liveCase{ID: id, Verdict: res.verdict, Wall: res.stats.wall.Milliseconds(), Worker: worker, Compare: compare}
+	if res.ok {
+		v := res.score
+		c.Score = &v
+	}
+	return c
+}
+
+// liveSnapshot は現在の進捗の状態を作ります。ロックした状態で呼び出してください。
+func liveSnapshot(c *liveCase) liveState {
+	st := liveState{
+		Title:    fmt.Sprintf("%s(%s)", cmn.ContestName, opt.setName),
+		Comment:  opt.logMsg,
+		Running:  append([]string(nil), ri.executingCase...),
+		OK:       ri.okCnt,
+		Failed:   append([]string{}, ri.failedTask...),
+		TLE:      append([]string{}, ri.tleTask...),
+		LastDist: append([]int(nil), ri.lastDist...)[:13],
+		BestDist: append([]int(nil), ri.bestDist...)[:13],
+		DecLast:  liveElems(ri.decLast),
+		DecBest:  liveElems(ri.decBest),
+		IncLast:  liveElems(ri.incLast),
+		IncBest:  liveElems(ri.incBest),
+		Case:     c,
+		Elapsed:  time.Since(ri.live.start).Round(time.Second).String(),
+		Status:   "running",
+	}
+	if ri.sched != nil {
+		st.Total, st.Done = ri.sched.tasks, ri.sched.finished
+		if d, ok := ri.sched.eta(cmn.Workers); ok {
+			st.ETA = d.Round(time.Second).String()
+		}
+	}
+	if ri.scoreCnt != 0 {
+		st.AM = ri.scoreSum / float64(ri.scoreCnt)
+	}
+	if ri.scoreLogCnt != 0 {
+		st.GM = math.Exp(ri.scoreLogSum / float64(ri.scoreLogCnt))
+	}
+	if len(opt.compare) != 0 {
+		st.HeadHead = headToHeadLine()
+	}
+	return st
+}
+
+// liveUpdate は進捗をページに送ります。cは直前に終わったケースで、ケースの開始時はnilです。
+func liveUpdate(mutex *sync.Mutex, c *liveCase) {
+	if ri.live == nil {
+		return
+	}
+	mutex.Lock()
+	st := liveSnapshot(c)
+	mutex.Unlock()
+	msg, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	ri.live.publish(msg)
+}
+
+// liveFinish は実行の終了をページに送り、配信を終えます。
+func liveFinish() {
+	if ri.live == nil {
+		return
+	}
+	st := liveSnapshot(nil)
+	st.Finished = true
+	switch {
+	case ri.stopped != nil:
+		st.Status = stopReport()
+	case ri.interrupted:
+		st.Status = "interrupted"
+	default:
+		st.Status = "finished"
+	}
+	if msg, err := json.Marshal(st); err == nil {
+		ri.live.publish(msg)
+	}
+	ri.live.close()
+}
+
+// startLiveServer は進捗を表示するHTTPサーバーを起動し、ブラウザを開きます。
+func startLiveServer() bool {
+	ln, url, err := listenWeb()
+	if err != nil {
+		errorPrint("Failed to start the web server: %v", err)
+		return false
+	}
+	hub := newLiveHub()
+	mux := http.NewServeMux()
+	mux.Handle("/assets/", assetHandler())
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		hub.wg.Add(1)
+		defer hub.wg.Done()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		ch := hub.subscribe()
+		defer hub.unsubscribe(ch)
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(w, "data: %s\n\n", msg)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if err := liveTmpl.Execute(w, map[string]interface{}{"Title": fmt.Sprintf("%s(%s)", cmn.ContestName, opt.setName)}); err != nil {
+			log.Printf("Template execution error: %v", err)
+		}
+	})
+	go http.Serve(ln, mux)
+	ri.live = hub
+
+	fmt.Printf("Live dashboard: %s\n", url)
+	openBrowser(url)
+	return true
+}
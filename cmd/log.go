@@ -2,7 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"math"
+	"os"
 	"sort"
 	"strconv"
 
@@ -25,19 +25,31 @@ var logCmd = &cobra.Command{
 }
 
 func showHistory() {
-	fmt.Printf("%-4s %-15s %10s %10s %10s     %s\n", "No.", "Date", "GM", "AM", "Error", "Comment")
+	fmt.Printf("%-4s %-15s %10s %10s %10s  %-8s   %s\n", "No.", "Date", "GM", "AM", "Error", "Commit", "Comment")
 	for i := max(len(logs.vals)-30, 0); i < len(logs.vals); i++ {
 		ave1, ave2, ngCnt := calcAverage(logs.vals[i])
-		fmt.Printf("%04d %-15s %10d %10d %10d     %s\n", logs.idxes[i], logs.times[i], ave1, ave2, ngCnt, logs.comments[i])
+		fmt.Printf("%04d %-15s %10s %10s %10d  %-8s   %s\n", logs.idxes[i], logs.times[i], formatMean(ave1), formatMean(ave2), ngCnt, logs.runs[i].Git.shortCommit(), logs.comments[i])
 	}
 }
 func showResults(id string) {
-	var d []int
+	var d []caseScore
+	var st []caseStats
+	var tr []trialStat
 	if len(logs.vals) == 0 {
 		return
 	}
 	d = logs.vals[len(logs.vals)-1]
+	st = logs.stats[logs.idxes[len(logs.idxes)-1]]
+	tr = logs.trials[logs.idxes[len(logs.idxes)-1]]
+	build := logs.builds[logs.idxes[len(logs.idxes)-1]]
+	gr := logs.runs[len(logs.runs)-1].Git
+	vd := logs.verdicts[logs.idxes[len(logs.idxes)-1]]
 	if id == "best" {
+		st = nil
+		tr = nil
+		build = ""
+		gr = nil
+		vd = nil
 		d = logs.best
 	} else {
 		tgt, err := strconv.Atoi(id)
@@ -49,6 +61,11 @@ func showResults(id string) {
 		for i := 0; i < len(logs.idxes); i++ {
 			if logs.idxes[i] == tgt {
 				d = logs.vals[i]
+				st = logs.stats[tgt]
+				tr = logs.trials[tgt]
+				build = logs.builds[tgt]
+				gr = logs.runs[i].Git
+				vd = logs.verdicts[tgt]
 				ok = true
 				break
 			}
@@ -62,15 +79,34 @@ func showResults(id string) {
 	if d == nil {
 		return
 	}
+	vf, err := parseVerdictFilter(opt.verdict)
+	if err != nil {
+		errorPrint("%v", err)
+		return
+	}
+	if vf != nil && vd == nil {
+		errorPrint("No verdicts recorded for this log")
+		return
+	}
 
 	type sl struct {
 		ratio float64
 		line  string
-		score int
+		score caseScore
 		rank  int
 	}
 	s := make([]sl, 0)
-	fmt.Printf("%-4s %8s %10s     %s  %s\n", "No.", "Score", "Rank", "Parameter", "Seed")
+	head := fmt.Sprintf("%-4s %8s %10s", "No.", "Score", "Rank")
+	if vd != nil {
+		head += fmt.Sprintf(" %-7s", "Verdict")
+	}
+	if st != nil {
+		head += fmt.Sprintf(" %8s %8s %10s", "Time", "CPU", "Memory")
+	}
+	if tr != nil {
+		head += fmt.Sprintf(" %-9s %s", "SD", "[Min, Max]")
+	}
+	fmt.Printf("%s     %s  %s\n", head, "Parameter", "Seed")
 
 	for i := 0; i < set.TestDataNum; i++ {
 		if len(opt.filter) != 0 {
@@ -79,29 +115,43 @@ func showResults(id string) {
 				continue
 			}
 		}
+		if vf != nil && !vf[vd[i]] {
+			continue
+		}
 		green := lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Bold(true)
 		sc := 0.0
 		var v string
 
-		v = fmt.Sprintf("%10d", d[i])
-		if d[i] < 0 {
+		v = fmt.Sprintf("%10s", formatScore(d[i]))
+		if !d[i].valid() {
 			sc = -1
 		}
 		r := calcRank(d[i], i)
 		v = green.Render(v)
+		var stc string
+		if vd != nil {
+			stc = fmt.Sprintf(" %-7s", vd[i])
+		}
+		if st != nil {
+			stc += fmt.Sprintf(" %6dms %6dms %8dKB", st[i].wall.Milliseconds(), st[i].cpu.Milliseconds(), st[i].maxRSS)
+		}
+		if tr != nil {
+			// 複数回実行した場合のばらつき(±標準偏差と最小・最大)
+			stc += fmt.Sprintf(" ±%-8.1f [%s, %s]", tr[i].sd, ftoa(tr[i].min), ftoa(tr[i].max))
+		}
 		var t string
 		if len(set.Seeds) != 0 {
 			if set.IsSystemTest {
-				t = fmt.Sprintf("%04d %s  %4d/%-4d %s   %s", i, v, r, len(logs.vals2), hi.HeaderData[i], set.Seeds[i])
+				t = fmt.Sprintf("%04d %s  %4d/%-4d%s %s   %s", i, v, r, len(logs.vals2), stc, hi.HeaderData[i], set.Seeds[i])
 			} else {
-				t = fmt.Sprintf("%04d %s  %4d/%-4d %s   %s", i, v, r, len(logs.vals), hi.HeaderData[i], set.Seeds[i])
+				t = fmt.Sprintf("%04d %s  %4d/%-4d%s %s   %s", i, v, r, len(logs.vals), stc, hi.HeaderData[i], set.Seeds[i])
 			}
 
 		} else {
 			if set.IsSystemTest {
-				t = fmt.Sprintf("%04d %s  %4d/%-4d %s   %d", i, v, r, len(logs.vals2), hi.HeaderData[i], i)
+				t = fmt.Sprintf("%04d %s  %4d/%-4d%s %s   %d", i, v, r, len(logs.vals2), stc, hi.HeaderData[i], i)
 			} else {
-				t = fmt.Sprintf("%04d %s  %4d/%-4d %s   %d", i, v, r, len(logs.vals), hi.HeaderData[i], i)
+				t = fmt.Sprintf("%04d %s  %4d/%-4d%s %s   %d", i, v, r, len(logs.vals), stc, hi.HeaderData[i], i)
 			}
 
 		}
@@ -120,21 +170,34 @@ func showResults(id string) {
 		})
 	}
 
-	s1 := 0
-	c1 := 0
-	t1 := 0.0
+	shown := make([]caseScore, 0)
 	for i := 0; i < limit; i++ {
 		fmt.Println(s[i].line)
-		if s[i].score > 0 {
-			c1++
-			s1 += s[i].score
-			t1 += math.Log(float64(s[i].score))
-		}
+		shown = append(shown, s[i].score)
 	}
-	if c1 != 0 {
+	if gm, am, ng := calcAverage(shown); len(shown)-ng > 0 {
 		fmt.Println("")
 		fmt.Println("[GM(AM)]")
-		fmt.Printf("%d(%d)\n", int(math.Round(math.Exp(t1/float64(c1)))), s1/c1)
+		fmt.Printf("%s(%s)\n", formatMean(gm), formatMean(am))
+	}
+	if build != "" || gr != nil {
+		fmt.Println("")
+	}
+	if build != "" {
+		fmt.Printf("Build: %s\n", build)
+	}
+	if gr != nil {
+		fmt.Printf("Commit: %s", gr.Commit)
+		if gr.Branch != "" {
+			fmt.Printf(" (%s)", gr.Branch)
+		}
+		if gr.Dirty {
+			fmt.Printf(" + uncommitted changes (%s/%s)", logs.logDir, gr.Diff)
+		}
+		fmt.Println("")
+	}
+	if id != "best" {
+		printStanding(d, "No. "+id)
 	}
 	fmt.Println("")
 
@@ -148,14 +211,25 @@ var logClearCmd = &cobra.Command{
 		commonInit()
 
 		fmt.Println("Logs cleared")
-		f := fmt.Sprintf("%s/%s", logs.logDir, RunCsv)
+		f := runStorePath()
 		renameFile(f, f+".1")
-		f = fmt.Sprintf("%s/%s", logs.logDir, HistoryCsv)
+		// 空のruns.jsonlを置き、旧形式のCSVからの移行が再び行われないようにする
+		writeToFile(f, []byte{}, false)
+		f = fmt.Sprintf("%s/%s", logs.logDir, DiffDir)
+		if dirExists(f) {
+			os.RemoveAll(f + ".1")
+			renameFile(f, f+".1")
+		}
+		f = fmt.Sprintf("%s/%s", logs.logDir, InputCsv)
 		renameFile(f, f+".1")
 		f = fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
 		renameFile(f, f+".1")
-		f = fmt.Sprintf("%s/%s", logs.logDir, InputCsv)
-		renameFile(f, f+".1")
+		if sd.Enable {
+			logs.runs = nil
+			if err := exportResultCsv(f); err != nil {
+				warningPrint("Failed to write %s: %v", f, err)
+			}
+		}
 	},
 }
 
@@ -168,7 +242,7 @@ var logDiffCmd = &cobra.Command{
 		if len(logs.vals) < 2 {
 			return
 		}
-		var d1, d2 []int
+		var d1, d2 []caseScore
 		var cap1, cap2 string
 		if len(args) == 0 {
 			d1 = logs.vals[len(logs.vals)-2]
@@ -236,8 +310,8 @@ var logDiffCmd = &cobra.Command{
 		type sl struct {
 			ratio  float64
 			line   string
-			score1 int
-			score2 int
+			score1 caseScore
+			score2 caseScore
 		}
 		s := make([]sl, 0)
 		for i := 0; i < set.TestDataNum; i++ {
@@ -247,23 +321,27 @@ var logDiffCmd = &cobra.Command{
 					continue
 				}
 			}
-			diff := d1[i] - d2[i]
+			diff := d1[i].v - d2[i].v
 			blue := lipgloss.NewStyle().Foreground(lipgloss.Color("4")).Bold(true)
 			red := lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
 			green := lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Bold(true)
 			sc := 0.0
 			var v1, v2, v3, v4 string
-			v1 = fmt.Sprintf("%10d", d1[i])
-			v2 = fmt.Sprintf("%10d", d2[i])
-			if d1[i] < 0 || d2[i] < 0 {
+			v1 = fmt.Sprintf("%10s", formatScore(d1[i]))
+			v2 = fmt.Sprintf("%10s", formatScore(d2[i]))
+			if !d1[i].valid() || !d2[i].valid() {
 				sc = -1
-				v3 = fmt.Sprintf("%10d", -1)
-				v4 = fmt.Sprintf("%6d", -1)
+				v3 = fmt.Sprintf("%10s", "-")
+				v4 = fmt.Sprintf("%6s", "-")
 				diff = 0
 			} else {
-				v3 = fmt.Sprintf("%10d", diff)
-				v4 = fmt.Sprintf("%0.2f%%", float64(d2[i])/float64(d1[i])*100)
-				sc = (float64(d2[i]) - float64(d1[i])) / float64(d1[i]) * 100
+				v3 = fmt.Sprintf("%10s", ftoa(diff))
+				if r, ok := scoreRatio(d2[i].v, d1[i]); ok {
+					v4 = fmt.Sprintf("%0.2f%%", d2[i].v/d1[i].v*100)
+					sc = r
+				} else {
+					v4 = fmt.Sprintf("%6s", "-")
+				}
 			}
 
 			if diff > 0 {
@@ -286,6 +364,17 @@ var logDiffCmd = &cobra.Command{
 			t := fmt.Sprintf("%04d\t%s\t%s\t%s\t%s\t%s", i, hi.HeaderData[i], v1, v2, v4, v3)
 			s = append(s, sl{sc, t, d1[i], d2[i]})
 		}
+		// 対応のある比較は表示件数(-c)に関係なく、フィルタに一致したすべてのケースで行う
+		all1 := make([]caseScore, len(s))
+		all2 := make([]caseScore, len(s))
+		for i := range s {
+			all1[i], all2[i] = s[i].score1, s[i].score2
+		}
+		paired := pairedAnalysis(all1, all2)
+		if opt.compact {
+			printPaired(paired, cap1, cap2, true)
+			return
+		}
 		limit := min(len(s), int(opt.linesLimit))
 
 		if opt.order == "asc" {
@@ -298,30 +387,61 @@ var logDiffCmd = &cobra.Command{
 			})
 		}
 
-		s1, s2 := 0, 0
-		c1, c2 := 0, 0
-		t1, t2 := 0.0, 0.0
+		shown1 := make([]caseScore, 0)
+		shown2 := make([]caseScore, 0)
 		for i := 0; i < limit; i++ {
 			fmt.Println(s[i].line)
-			if s[i].score1 > 0 {
-				c1++
-				s1 += s[i].score1
-				t1 += math.Log(float64(s[i].score1))
-			}
-			if s[i].score2 > 0 {
-				c2++
-				s2 += s[i].score2
-				t2 += math.Log(float64(s[i].score2))
-			}
+			shown1 = append(shown1, s[i].score1)
+			shown2 = append(shown2, s[i].score2)
 		}
 
-		if c1 != 0 && c2 != 0 {
+		gm1, am1, ng1 := calcAverage(shown1)
+		gm2, am2, ng2 := calcAverage(shown2)
+		if len(shown1)-ng1 > 0 && len(shown2)-ng2 > 0 {
 			fmt.Println("")
 			fmt.Println("[GM(AM)]")
-			fmt.Printf("%s : %d(%d)\n", cap1, int(math.Round(math.Exp(t1/float64(c1)))), s1/c1)
-			fmt.Printf("%s : %d(%d)\n", cap2, int(math.Round(math.Exp(t2/float64(c2)))), s2/c2)
+			fmt.Printf("%s : %s(%s)\n", cap1, formatMean(gm1), formatMean(am1))
+			fmt.Printf("%s : %s(%s)\n", cap2, formatMean(gm2), formatMean(am2))
 		}
+		printPaired(paired, cap1, cap2, false)
+
+	},
+}
 
+var logCheckoutCmd = &cobra.Command{
+	Use:   "checkout <No.>",
+	Short: "restore the source of a log into a git worktree",
+	Long:  `Create a git worktree at the commit recorded for the log and apply its uncommitted changes.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		no, err := strconv.Atoi(args[0])
+		if err != nil {
+			errorPrint("Invalid log number: %s", args[0])
+			os.Exit(1)
+		}
+		if err := checkoutRun(no, opt.worktreeDir); err != nil {
+			errorPrint("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var logExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export result.csv",
+	Long:  `Write the logged runs and the official standings (if any) as result.csv for the standings page.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		path := opt.exportPath
+		if path == "" {
+			path = fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
+		}
+		if err := exportResultCsv(path); err != nil {
+			errorPrint("Failed to write %s: %v", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %s\n", path)
 	},
 }
 
@@ -331,11 +451,19 @@ func init() {
 	logCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
 	logCmd.Flags().StringVarP(&opt.order, "order", "o", "", "asc or desc")
 	logCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
+	logCmd.Flags().StringVar(&opt.verdict, "verdict", "", "Show only the cases with the given verdicts (e.g. RE,TLE)")
 	logCmd.AddCommand(logClearCmd)
 	logClearCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	logCmd.AddCommand(logCheckoutCmd)
+	logCheckoutCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	logCheckoutCmd.Flags().StringVarP(&opt.worktreeDir, "dir", "d", "", "Worktree directory (default: ../<repository>-<No.>)")
+	logCmd.AddCommand(logExportCmd)
+	logExportCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	logExportCmd.Flags().StringVarP(&opt.exportPath, "output", "o", "", "Output file (default: logs/<set>/result.csv)")
 	logCmd.AddCommand(logDiffCmd)
 	logDiffCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
 	logDiffCmd.Flags().StringVarP(&opt.order, "order", "o", "", "asc or desc")
 	logDiffCmd.Flags().IntVarP(&opt.linesLimit, "count", "c", INF, "max data size")
 	logDiffCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
+	logDiffCmd.Flags().BoolVar(&opt.compact, "compact", false, "Print only the paired comparison in one line")
 }
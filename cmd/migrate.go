@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 旧形式のCSVログ(history.csv, run.csv, result.csv)からruns.jsonlへの移行です。
+// 移行後のCSVは{ファイル名}.bakとして残します。
+
+var legacyCsvs = []string{HistoryCsv, RunCsv, ResultCsv}
+
+// ownResultRow はresult.csvのうちhc runで追加した行(「{ログ番号}:{コメント}」で始まる)に一致します。
+var ownResultRow = regexp.MustCompile(`^\d{4}:`)
+
+// migrateCsvLogs はruns.jsonlがなく旧形式のCSVがある場合に、CSVの内容をruns.jsonlに移します。
+func migrateCsvLogs() {
+	if fileExists(runStorePath()) {
+		return
+	}
+	found := false
+	for _, name := range legacyCsvs {
+		if fileExists(fmt.Sprintf("%s/%s", logs.logDir, name)) {
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+
+	historyCsv := fmt.Sprintf("%s/%s", logs.logDir, HistoryCsv)
+	hist := make([]runRecord, 0)
+	for _, line := range readFileLines(historyCsv) {
+		if rec, ok := parseLegacyHistoryLine(line); ok {
+			hist = append(hist, rec)
+		}
+	}
+
+	// run.csvの順に並べ、history.csvにも記録された実行(時刻とコメントが同じもの)は1件にまとめる
+	recs := make([]runRecord, 0, len(hist))
+	used := make([]bool, len(hist))
+	for _, line := range readFileLines(fmt.Sprintf("%s/%s", logs.logDir, RunCsv)) {
+		rec, ok := parseLegacyRunLine(line)
+		if !ok {
+			continue
+		}
+		for k := range hist {
+			if used[k] || !hist[k].Time.Equal(rec.Time) || hist[k].Comment != rec.Comment {
+				continue
+			}
+			used[k] = true
+			// run.csvの平均は丸められているため、集計はケースごとのスコアから計算し直す
+			hist[k].Summary = summarize(hist[k].scores())
+			rec = hist[k]
+			break
+		}
+		recs = append(recs, rec)
+	}
+	for k := range hist {
+		if !used[k] {
+			recs = append(recs, hist[k])
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Time.Before(recs[j].Time) })
+
+	// result.csvの公式の行はstandings.csvに移す
+	resultCsv := fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
+	standingsCsv := fmt.Sprintf("%s/%s", logs.logDir, StandingsCsv)
+	if lc := readFileLines(resultCsv); len(lc) > 0 && !fileExists(standingsCsv) {
+		official := make([]string, 0)
+		for _, l := range lc[1:] {
+			if !ownResultRow.MatchString(l) {
+				official = append(official, l)
+			}
+		}
+		if len(official) > 0 {
+			t := strings.Join(append([]string{lc[0]}, official...), "\n") + "\n"
+			if err := writeToFile(standingsCsv, []byte(t), false); err != nil {
+				warningPrint("Failed to write %s: %v", standingsCsv, err)
+				return
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, rec := range recs {
+		rec.Schema = StoreSchema
+		if rec.Summary.Cases == 0 {
+			rec.Summary = summarize(rec.scores())
+		}
+		b, err := jsonLine(rec)
+		if err != nil {
+			warningPrint("Failed to convert a log record: %v", err)
+			continue
+		}
+		sb.Write(b)
+	}
+	if err := writeToFile(runStorePath(), []byte(sb.String()), false); err != nil {
+		warningPrint("Failed to write %s: %v", runStorePath(), err)
+		return
+	}
+	for _, name := range legacyCsvs {
+		f := fmt.Sprintf("%s/%s", logs.logDir, name)
+		if fileExists(f) {
+			renameFile(f, f+".bak")
+		}
+	}
+	warningPrint("Migrated %d runs from the CSV logs in %s to %s (the CSV files are kept as *.bak)", len(recs), logs.logDir, RunsJsonl)
+}
+
+// parseLegacyTime はstringTimeの形式(年なし)の時刻を読み込みます。未来の日付になる場合は前年とします。
+func parseLegacyTime(s string) time.Time {
+	now := time.Now()
+	t, err := time.ParseInLocation("2006/01/02 15:04:05", fmt.Sprintf("%d/%s", now.Year(), s), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	if t.After(now) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// parseLegacyHistoryLine はhistory.csvの1行(時刻,ログ番号,コメント,ケースごとのスコア)を読み込みます。
+// スコアは整数で-1が失敗、0が未実行です。コメントにカンマが含まれる場合はケース数から区切りを判断します。
+func parseLegacyHistoryLine(line string) (runRecord, bool) {
+	ls := strings.Split(line, ",")
+	if len(ls) < 3 {
+		return runRecord{}, false
+	}
+	no, err := strconv.Atoi(ls[1])
+	if err != nil {
+		return runRecord{}, false
+	}
+	end := 3
+	if n := len(ls) - (set.TestDataNum + 1); n > end {
+		end = n
+	}
+	rec := runRecord{No: no, Time: parseLegacyTime(ls[0]), Comment: strings.Join(ls[2:end], ","), Complete: true}
+	for j, v := range ls[end:] {
+		sc := parseLegacyScoreField(v)
+		switch sc.st {
+		case scoreOK:
+			s := sc.v
+			rec.Cases = append(rec.Cases, caseRecord{ID: j, Score: &s})
+		case scoreFailed:
+			rec.Cases = append(rec.Cases, caseRecord{ID: j})
+		}
+	}
+	return rec, true
+}
+
+// parseLegacyRunLine はrun.csvの1行(時刻,コメント,成功数,失敗数,合計,GM,AM)を読み込みます。
+func parseLegacyRunLine(line string) (runRecord, bool) {
+	ls := strings.Split(line, ",")
+	if len(ls) < 7 {
+		return runRecord{}, false
+	}
+	rec := runRecord{No: -1, Time: parseLegacyTime(ls[0]), Complete: true}
+	n := len(ls)
+	rec.Comment = strings.Join(ls[1:n-5], ",")
+	rec.Summary.OK, _ = strconv.Atoi(ls[n-5])
+	rec.Summary.NG, _ = strconv.Atoi(ls[n-4])
+	rec.Summary.Sum, _ = strconv.ParseFloat(ls[n-3], 64)
+	rec.Summary.GM, _ = strconv.ParseFloat(ls[n-2], 64)
+	rec.Summary.AM, _ = strconv.ParseFloat(ls[n-1], 64)
+	rec.Summary.Cases = set.TestDataNum
+	return rec, true
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -81,8 +83,24 @@ func headReader(dir string, fileName string) []string {
 	return strings.Fields(head)
 }
 
+// errTimeLimitExceeded は実行時間が制限を超えたことを表します。
+var errTimeLimitExceeded = errors.New("time limit exceeded")
+
+// errInterrupted は実行が中断されたことを表します。
+var errInterrupted = errors.New("interrupted")
+
+// runContext はテスト実行の中断を通知するコンテキストを返します。
+func runContext() context.Context {
+	if ri.ctx == nil {
+		return context.Background()
+	}
+	return ri.ctx
+}
+
 // ExecuteWithFileInput はファイルから入力を読み込んでプログラムを実行します。
-func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, displayStderr bool) (stdout string, stderr string, execErr error) {
+// timeLimitが0より大きい場合、制限時間を超えたプロセスはプロセスグループごと強制終了され、errTimeLimitExceededを返します。
+// statsには経過時間、CPU時間(user+sys)、最大常駐メモリを返します。envは子プロセスにだけ追加する環境変数です。
+func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, displayStderr bool, timeLimit time.Duration, env []string) (stdout string, stderr string, stats caseStats, execErr error) {
 	if opt.debugMode {
 		debugPrint("ExecuteWithFileInput: file path: %s", filePath)
 		debugPrint("ExecuteWithFileInput: command: %v", cmd)
@@ -93,7 +111,7 @@ func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, dis
 		if opt.debugMode {
 			debugPrint("ExecuteWithFileInput: file not found: %s", filePath)
 		}
-		return "", "", err
+		return "", "", stats, err
 	}
 
 	data, err := ioutil.ReadFile(filePath)
@@ -101,7 +119,7 @@ func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, dis
 		if opt.debugMode {
 			debugPrint("ExecuteWithFileInput: failed to read file: %s: %v", filePath, err)
 		}
-		return "", "", fmt.Errorf("failed to read file: %s: %v", filePath, err)
+		return "", "", stats, fmt.Errorf("failed to read file: %s: %v", filePath, err)
 	}
 	if opt.debugMode {
 		debugPrint("ExecuteWithFileInput: successfully read file: %s (size: %d bytes)", filePath, len(data))
@@ -115,6 +133,9 @@ func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, dis
 		c = exec.Command(cmd[0])
 	}
 	c.Stdin = bytes.NewReader(data)
+	if len(env) != 0 {
+		c.Env = append(os.Environ(), env...)
+	}
 	// Get the output from both stdout and stderr
 	var outb, errb bytes.Buffer
 
@@ -128,11 +149,33 @@ func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, dis
 		c.Stdout = &outb
 		c.Stderr = &errb
 	}
+	// ジャッジがソルバーを起動する場合もまとめて終了できるようにプロセスグループを分ける
+	setProcessGroup(c)
+	// 子孫プロセスがパイプを握ったままでもWaitが戻るようにする
+	c.WaitDelay = time.Second
 	// Execute the command
 	if opt.debugMode {
 		debugPrint("ExecuteWithFileInput: executing command")
 	}
-	err = c.Run()
+	start := time.Now()
+	if err = c.Start(); err != nil {
+		return "", "", stats, err
+	}
+	err = waitProcess(c, timeLimit)
+	switch err {
+	case errTimeLimitExceeded:
+		stats = processStats(c.ProcessState, time.Since(start))
+		if opt.debugMode {
+			debugPrint("ExecuteWithFileInput: time limit exceeded (%v)", timeLimit)
+		}
+		return outb.String(), errb.String(), stats, errTimeLimitExceeded
+	case errInterrupted:
+		if opt.debugMode {
+			debugPrint("ExecuteWithFileInput: interrupted")
+		}
+		return outb.String(), errb.String(), stats, errInterrupted
+	}
+	stats = processStats(c.ProcessState, time.Since(start))
 	if err != nil {
 		if opt.debugMode {
 			debugPrint("ExecuteWithFileInput: command error: %v", err)
@@ -142,7 +185,7 @@ func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, dis
 				debugPrint("ExecuteWithFileInput: first 100 chars of stderr: %s", truncString(errb.String(), 100))
 			}
 		}
-		return outb.String(), errb.String(), err
+		return outb.String(), errb.String(), stats, err
 	}
 
 	if opt.debugMode {
@@ -150,7 +193,67 @@ func ExecuteWithFileInput(filePath string, cmd []string, displayStdout bool, dis
 		debugPrint("ExecuteWithFileInput: stdout length: %d", outb.Len())
 		debugPrint("ExecuteWithFileInput: stderr length: %d", errb.Len())
 	}
-	return outb.String(), errb.String(), nil
+	return outb.String(), errb.String(), stats, nil
+}
+
+// waitProcess は起動したプロセスの終了を待ちます。
+// timeLimitを超えた場合はerrTimeLimitExceeded、Ctrl-Cなどで中断された場合はerrInterruptedを返し、プロセスグループごと強制終了します。
+func waitProcess(c *exec.Cmd, timeLimit time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+	var timeout <-chan time.Time
+	if timeLimit > 0 {
+		timer := time.NewTimer(timeLimit)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-timeout:
+		killProcessGroup(c)
+		<-done
+		return errTimeLimitExceeded
+	case <-runContext().Done():
+		killProcessGroup(c)
+		<-done
+		return errInterrupted
+	}
+}
+
+// executeJudge はジャッジ(テスター)を実行し、標準出力と標準エラー出力をまとめて返します。
+// ソルバーと同じく、timeLimitを超えた場合や中断された場合はプロセスグループごと強制終了します。
+func executeJudge(command []string, timeLimit time.Duration) (string, error) {
+	if opt.debugMode {
+		debugPrint("executeJudge: running command: %v", command)
+	}
+	c := exec.Command(command[0], command[1:]...)
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	setProcessGroup(c)
+	c.WaitDelay = time.Second
+	if err := c.Start(); err != nil {
+		return "", err
+	}
+	err := waitProcess(c, timeLimit)
+	if err != nil && opt.debugMode {
+		debugPrint("executeJudge: command error: %v", err)
+	}
+	return out.String(), err
+}
+
+// processStats は終了したプロセスの実行時間とメモリ使用量をまとめます。
+func processStats(ps *os.ProcessState, wall time.Duration) caseStats {
+	st := caseStats{wall: wall}
+	if ps == nil {
+		return st
+	}
+	st.cpu = ps.UserTime() + ps.SystemTime()
+	st.maxRSS = maxRSS(ps)
+	return st
 }
 
 // executeCommand は単一のコマンドを実行し、その出力を返します。
@@ -338,25 +441,61 @@ func stringsToCsv(s []string) string {
 	return string(t)
 }
 
-// intsToCsv は整数の配列をCSV形式の文字列に変換します。
-func intsToCsv(a []int) string {
+func floatsToCsv(f []float64) string {
 	t := make([]byte, 0)
-	for i := 0; i < len(a); i++ {
-		s := strconv.Itoa(a[i])
+	for i := 0; i < len(f); i++ {
+		s := fmt.Sprintf("%f", f[i])
 		t = append(t, []byte(s)...)
 		t = append(t, ',')
 	}
 	return string(t)
 }
-func floatsToCsv(f []float64) string {
+
+// statsToCsv は実行時間とメモリ使用量を「経過時間ms:CPU時間ms:メモリKB」の形式でCSVに変換します。
+func statsToCsv(a []caseStats) string {
 	t := make([]byte, 0)
-	for i := 0; i < len(f); i++ {
-		s := fmt.Sprintf("%f", f[i])
+	for i := 0; i < len(a); i++ {
+		s := fmt.Sprintf("%d:%d:%d", a[i].wall.Milliseconds(), a[i].cpu.Milliseconds(), a[i].maxRSS)
+		if a[i].judgeWall > 0 || a[i].judgeCpu > 0 {
+			s += fmt.Sprintf(":%d:%d", a[i].judgeWall.Milliseconds(), a[i].judgeCpu.Milliseconds())
+		}
 		t = append(t, []byte(s)...)
 		t = append(t, ',')
 	}
 	return string(t)
 }
+
+// parseStats はstatsToCsvで出力した1要素を読み込みます。
+func parseStats(s string) caseStats {
+	f := strings.Split(s, ":")
+	if len(f) != 3 && len(f) != 5 {
+		return caseStats{}
+	}
+	w, _ := strconv.ParseInt(f[0], 10, 64)
+	c, _ := strconv.ParseInt(f[1], 10, 64)
+	m, _ := strconv.ParseInt(f[2], 10, 64)
+	st := caseStats{wall: time.Duration(w) * time.Millisecond, cpu: time.Duration(c) * time.Millisecond, maxRSS: m}
+	if len(f) == 5 {
+		jw, _ := strconv.ParseInt(f[3], 10, 64)
+		jc, _ := strconv.ParseInt(f[4], 10, 64)
+		st.judgeWall = time.Duration(jw) * time.Millisecond
+		st.judgeCpu = time.Duration(jc) * time.Millisecond
+	}
+	return st
+}
+
+// percentile はaのpパーセンタイル値を返します(最近傍法)。
+func percentile(a []float64, p float64) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	b := make([]float64, len(a))
+	copy(b, a)
+	sort.Float64s(b)
+	k := int(math.Ceil(p/100*float64(len(b)))) - 1
+	k = min(max(k, 0), len(b)-1)
+	return b[k]
+}
 func itoa(x int) string {
 	return strconv.Itoa(x)
 }
@@ -405,26 +544,6 @@ func renameFile(oldName, newName string) error {
 	return nil
 }
 
-func calcAverage(a []int) (int, int, int) {
-	ls := 0.0
-	s := 0
-	ok := 0
-	ng := 0
-	for i := 0; i < len(a); i++ {
-		if a[i] <= 0 {
-			ng++
-		} else {
-			ok++
-			s += a[i]
-			ls += math.Log(float64(a[i]))
-		}
-	}
-	if ok == 0 {
-		ok = 1
-	}
-	return int(math.Round(math.Exp(ls / float64(ok)))), s / ok, ng
-}
-
 type FileReader struct {
 	fileName string
 	err      error
@@ -465,55 +584,11 @@ func (fr *FileReader) rf() float64 {
 func (fr *FileReader) close() {
 	fr.file.Close()
 }
-func insertLine(filename string, lineNumber int, newLine string) error {
-	// ファイルを開く
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("ファイルを開く際にエラーが発生しました: %v", err)
-	}
-	defer file.Close()
-
-	// ファイル全体を読み込む
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("ファイルを読み込む際にエラーが発生しました: %v", err)
-	}
-
-	// 新しい行を指定した位置に挿入
-	if lineNumber > len(lines) {
-		lineNumber = len(lines) + 1
-	}
-	lines = append(lines[:lineNumber-1], append([]string{newLine}, lines[lineNumber-1:]...)...)
-
-	// ファイルに書き込み
-	file, err = os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("ファイルを作成する際にエラーが発生しました: %v", err)
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	for _, line := range lines {
-		if _, err := writer.WriteString(line + "\n"); err != nil {
-			return fmt.Errorf("ファイルに書き込む際にエラーが発生しました: %v", err)
-		}
-	}
-	if err := writer.Flush(); err != nil {
-		return fmt.Errorf("ファイルをフラッシュする際にエラーが発生しました: %v", err)
-	}
-
-	return nil
-}
-
 func dbg(file string, s ...interface{}) {
 	pc, _, line, ok := runtime.Caller(1)
 	if ok {
 		funcName := runtime.FuncForPC(pc).Name()
-		msg := fmt.Sprintf("%s:%d:%s\n", funcName, line, fmt.Sprint(s))
+		msg := fmt.Sprintf("%s:%d:%s\n", funcName, line, fmt.Sprint(s...))
 		writeToFile(file, []byte(msg), true)
 	}
 }
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// setProcessGroup は子プロセスを新しいプロセスグループで起動するように設定します。
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup は子プロセスとその子孫をまとめて強制終了します。
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	// 負のPIDを指定するとプロセスグループ全体にシグナルが送られる
+	if err := syscall.Kill(-c.Process.Pid, syscall.SIGKILL); err != nil {
+		c.Process.Kill()
+	}
+}
+
+// maxRSS は終了したプロセスの最大常駐メモリをKB単位で返します。
+func maxRSS(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// macOSのru_maxrssはバイト単位、Linuxはキロバイト単位
+	if runtime.GOOS == "darwin" {
+		return int64(ru.Maxrss) / 1024
+	}
+	return int64(ru.Maxrss)
+}
+
+// isAddrInUse はerrがポートが使用中であることによるエラーかどうかを返します。
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
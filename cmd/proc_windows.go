@@ -0,0 +1,38 @@
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup はWindowsでは何もしません。
+func setProcessGroup(c *exec.Cmd) {
+}
+
+// killProcessGroup は子プロセスとその子孫をまとめて強制終了します。
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	// taskkill /T で子孫プロセスも含めて終了させる
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run()
+	if err != nil {
+		c.Process.Kill()
+	}
+}
+
+// maxRSS はWindowsでは取得できないため0を返します。
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
+
+// isAddrInUse はerrがポートが使用中であることによるエラーかどうかを返します。
+func isAddrInUse(err error) bool {
+	// WindowsではEADDRINUSEではなくWSAEADDRINUSE(10048)が返る
+	return errors.Is(err, syscall.Errno(10048))
+}
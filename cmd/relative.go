@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// DefaultRelEval は[standings]のRelEvalが設定されていない場合の1ケースあたりの満点です。
+const DefaultRelEval = 100000000
+
+// relEval は相対評価の1ケースあたりの満点を返します。
+func relEval() float64 {
+	if sd.RelEval > 0 {
+		return float64(sd.RelEval)
+	}
+	return DefaultRelEval
+}
+
+// relativeCaseScore は順位表と同じ方法で1ケースの相対スコアを求めます。
+// 最大化問題ではRelEval*自分/ベスト、最小化問題ではRelEval*ベスト/自分を四捨五入し、失敗は0点です。
+func relativeCaseScore(sc, best caseScore) float64 {
+	if !sc.valid() || !best.valid() {
+		return 0
+	}
+	if cmn.IsRankMin {
+		if sc.v <= 0 {
+			return 0
+		}
+		return math.Round(relEval() * best.v / sc.v)
+	}
+	if best.v <= 0 {
+		return 0
+	}
+	return math.Round(relEval() * sc.v / best.v)
+}
+
+// relativeScore はidsのケースの相対スコアの合計を返します。
+func relativeScore(sc, best []caseScore, ids []int) float64 {
+	s := 0.0
+	for _, i := range ids {
+		s += relativeCaseScore(sc[i], best[i])
+	}
+	return s
+}
+
+// relativeBest は相対評価の基準となるケースごとのベストを返します。
+// システムテストのセットでは公式の順位表(result.csv)のベストも含めます。
+func relativeBest() []caseScore {
+	best := make([]caseScore, set.TestDataNum)
+	copy(best, logs.best)
+	if set.IsSystemTest && len(logs.best2) == set.TestDataNum {
+		for i := range best {
+			best[i] = updateBest(best[i], logs.best2[i])
+		}
+	}
+	return best
+}
+
+// filteredIDs は-fのフィルタに一致するケース番号を返します。
+func filteredIDs() []int {
+	return filteredIDsBy(opt.filter)
+}
+
+// filteredIDsBy はフィルタfilterに一致するケースの番号を返します。
+func filteredIDsBy(filter string) []int {
+	ids := make([]int, 0, set.TestDataNum)
+	for i := 0; i < set.TestDataNum; i++ {
+		if !filterMatch(filter, hi.HeaderData[i]) {
+			continue
+		}
+		ids = append(ids, i)
+	}
+	return ids
+}
+
+// standingsRanks は合計点の高い順に順位表と同じ順位(同点は同順位)を付けます。返り値は並べ替えた添字と順位です。
+func standingsRanks(scores []float64) ([]int, []int) {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return scores[idx[i]] > scores[idx[j]] })
+	ranks := make([]int, len(idx))
+	for k := range idx {
+		if k > 0 && scores[idx[k]] == scores[idx[k-1]] {
+			ranks[k] = ranks[k-1]
+		} else {
+			ranks[k] = k + 1
+		}
+	}
+	return idx, ranks
+}
+
+var logRelativeCmd = &cobra.Command{
+	Use:   "relative",
+	Short: "rank the logged runs by relative score",
+	Long: `Rank the logged runs by the relative score of the standings.
+Each case is scored against the best score of all logged runs (and of the official result.csv for system sets), scaled by RelEval.
+Incomplete runs are not ranked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		if len(logs.vals) == 0 {
+			fmt.Println("No logged runs")
+			return
+		}
+		showRelative()
+	},
+}
+
+func showRelative() {
+	best := relativeBest()
+	ids := filteredIDs()
+	// 途中で中断した実行は実行していないケースが0点になるため、ベストと同じく順位の対象にしない
+	pos := make([]int, 0, len(logs.vals))
+	for i := range logs.vals {
+		if logs.runs[i].Complete {
+			pos = append(pos, i)
+		}
+	}
+	scores := make([]float64, len(pos))
+	for k, i := range pos {
+		scores[k] = relativeScore(logs.vals[i], best, ids)
+	}
+	idx, ranks := standingsRanks(scores)
+
+	full := relEval() * float64(len(ids))
+	fmt.Printf("%-5s %-4s %16s %8s %10s %6s  %-15s %s\n", "Rank", "No.", "Relative", "Rate", "GM", "Error", "Date", "Comment")
+	for k := 0; k < min(len(idx), int(opt.linesLimit)); k++ {
+		i := pos[idx[k]]
+		sel := make([]caseScore, len(ids))
+		for j, id := range ids {
+			sel[j] = logs.vals[i][id]
+		}
+		gm, _, ng := calcAverage(sel)
+		rate := 0.0
+		if full > 0 {
+			rate = scores[idx[k]] / full * 100
+		}
+		fmt.Printf("%-5d %04d %16s %7.3f%% %10s %6d  %-15s %s\n", ranks[k], logs.idxes[i], ftoa(scores[idx[k]]), rate, formatMean(gm), ng, logs.times[i], logs.comments[i])
+	}
+}
+
+func init() {
+	logCmd.AddCommand(logRelativeCmd)
+	logRelativeCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	logRelativeCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
+	logRelativeCmd.Flags().IntVarP(&opt.linesLimit, "count", "c", INF, "max data size")
+}
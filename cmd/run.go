@@ -1,17 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
@@ -28,57 +32,191 @@ var runCmd = &cobra.Command{
 
 		runtimeInit()
 
+		if len(opt.compare) != 0 && (opt.resume || opt.target != -1) {
+			errorPrint("--compare cannot be used with --resume or --target")
+			return
+		}
+		if opt.stopIfWorse > 0 {
+			if len(opt.compare) != 0 {
+				errorPrint("--stop-if-worse cannot be used with --compare")
+				return
+			}
+			if opt.stopRef != "best" && opt.stopRef != "last" {
+				errorPrint("--stop-ref must be best or last")
+				return
+			}
+			if logs.isBlank {
+				warningPrint("No logged runs to compare with. --stop-if-worse is ignored.")
+				opt.stopIfWorse = 0
+			}
+		}
+		// 古いバイナリで実行しないよう、先にビルドする
+		hash, ok := autoBuild()
+		if !ok {
+			os.Exit(1)
+		}
+		ri.buildHash = hash
+		// ビルドしたソースの状態を記録する
+		if ri.enableLogStandings {
+			ri.git = currentGitState()
+		}
+		//中断した実行の再開(--resume)の場合
+		if opt.resume {
+			if !resumeCheckpoint() {
+				return
+			}
+		}
+
 		//テストID指定(-t)の場合
 		if opt.target != -1 {
 			if opt.target >= set.TestDataNum || opt.target < 0 {
 				errorPrint("The test number is out of range")
 				return
 			}
+			// 子プロセスは別のプロセスグループで動くため、Ctrl-Cを受けたら明示的に終了させる
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			ri.ctx = ctx
 			runSingleCmd(fmt.Sprintf("%04d", ri.testID[0]))
+			stop()
 			return
 		}
 
 		if !opt.quietMode {
 			printTitle()
 		}
-		workerPool()
+		if ri.enableLog && !opt.resume && len(opt.compare) == 0 && opt.loop == 1 {
+			startCheckpoint()
+		}
+		if opt.web && !startLiveServer() {
+			return
+		}
+		if len(ri.testID) > 0 {
+			workerPool()
+		}
+		liveFinish()
 
+		if ri.stopped != nil {
+			// 打ち切った結果は--save-partialを指定した場合だけ履歴に残す
+			fmt.Println("")
+			warningPrint("%s", stopReport())
+			removeCheckpoint()
+			if !opt.savePartial {
+				ri.enableLog = false
+				ri.enableLogStandings = false
+			}
+			printLog()
+			return
+		}
+		if ri.interrupted {
+			// 中断された場合は途中までの結果を表示し、確認のうえ履歴に残す
+			saved := ri.enableLogStandings && confirmSavePartial()
+			if ri.enableLogStandings && !saved {
+				ri.enableLog = false
+				ri.enableLogStandings = false
+			}
+			if len(opt.compare) != 0 {
+				printCompareLog()
+			} else {
+				printLog()
+			}
+			if saved {
+				// 履歴に残した実行を--resumeでもう一度記録しないよう、チェックポイントを消す
+				removeCheckpoint()
+			} else if ri.checkpoint {
+				warningPrint("Interrupted. Run 'hc run --resume' to continue from the checkpoint.")
+			}
+			return
+		}
 		//printLargeScore(5)
-		if ri.enableLog {
+		if len(opt.compare) != 0 {
+			printCompareLog()
+		} else if ri.enableLog {
 			printLog()
 		}
+		removeCheckpoint()
 	},
 }
 
-func printLog() {
-	sort.Slice(ri.score, func(i, j int) bool {
-		return ri.score[i].a < ri.score[j].a
-	})
+// confirmSavePartial は中断された実行結果を履歴に保存するかを確認します。
+func confirmSavePartial() bool {
+	if opt.savePartial {
+		return true
+	}
+	if opt.quietMode {
+		return false
+	}
+	save := false
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Interrupted (%d/%d completed). Save the partial results to the log?", countCompleted(), len(ri.testID)),
+	}
+	if err := survey.AskOne(prompt, &save); err != nil {
+		return false
+	}
+	return save
+}
+
+// isCompleted は中断された実行でケースiの結果が得られているかを返します。
+func isCompleted(i int) bool {
+	return !ri.interrupted || ri.done[i]
+}
+
+// countCompleted は結果が得られたケース数を返します。
+func countCompleted() int {
+	cnt := 0
+	for i := 0; i < len(ri.done); i++ {
+		if ri.done[i] {
+			cnt++
+		}
+	}
+	return cnt
+}
 
+func printLog() {
 	fs := make([]string, 0)
+	ts := make([]string, 0)
+	verdicts := make(map[string]int)
 	for i := 0; i < len(ri.score); i++ {
-		if ri.score[i].a >= 0 && ri.score[i].b == 0 {
-			ri.score[i].b = -1
-			fs = append(fs, fmt.Sprintf("%04d", ri.score[i].a))
+		if !isCompleted(i) {
+			continue
+		}
+		v := caseVerdict(i)
+		verdicts[v]++
+		if !ri.score[i].valid() {
+			ri.score[i].st = scoreFailed
+			if ri.tle[i] {
+				ts = append(ts, fmt.Sprintf("%04d", i))
+			} else {
+				fs = append(fs, fmt.Sprintf("%04d(%s)", i, v))
+			}
 		}
 
 	}
 
-	tot, ave, cntOk, cntNg := 0, 0, 0, 0
-	totLog := 0.0
-	aveLog := 0
+	cntOk, cntNg, cntTle := 0, 0, 0
+	tot := 0.0
 	for i := 0; i < set.TestDataNum; i++ {
-		if ri.score[i].b > 0 {
+		if !isCompleted(i) {
+			continue
+		}
+		if ri.score[i].valid() {
 			cntOk++
-			tot += ri.score[i].b
-			totLog += math.Log(float64(ri.score[i].b))
+			tot += ri.score[i].v
+		} else if ri.tle[i] {
+			cntTle++
 		} else {
 			cntNg++
 		}
 	}
-	if cntOk > 0 {
-		ave = tot / cntOk
-		aveLog = int(math.Round(math.Exp(totLog / float64(cntOk))))
+	aveLog, ave, _ := calcAverage(ri.score)
+	caseCount := fmt.Sprintf("%d", set.TestDataNum)
+	logMsg := opt.logMsg
+	if len(ri.logLabel) != 0 {
+		logMsg = fmt.Sprintf("%s [%s]", opt.logMsg, ri.logLabel)
+	}
+	if ri.interrupted {
+		// 途中で中断された結果であることがわかるようにする
+		caseCount = fmt.Sprintf("%d/%d", cntOk+cntNg+cntTle, set.TestDataNum)
+		logMsg = fmt.Sprintf("[incomplete %d/%d] %s", cntOk+cntNg+cntTle, set.TestDataNum, logMsg)
 	}
 	// Optput
 	// lipglossスタイルの定義
@@ -86,67 +224,111 @@ func printLog() {
 	dataStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("white"))
 
 	// ヘッダー
-	header := fmt.Sprintf("%s%s%s%s%s", headerStyle.Width(20).Align(lipgloss.Left).Render("Date"),
+	header := fmt.Sprintf("%s%s%s%s%s%s", headerStyle.Width(20).Align(lipgloss.Left).Render("Date"),
 		headerStyle.Width(20).Align(lipgloss.Left).Render("Geometric Mean"),
 		headerStyle.Width(20).Align(lipgloss.Left).Render("Arithmetic Mean"),
 		headerStyle.Width(20).Align(lipgloss.Left).Render("Test Case Count"),
-		headerStyle.Width(20).Align(lipgloss.Left).Render("Error Count"))
+		headerStyle.Width(20).Align(lipgloss.Left).Render("Error Count"),
+		headerStyle.Width(20).Align(lipgloss.Left).Render("TLE Count"))
 	// データ
-	data := fmt.Sprintf("%s%s%s%s%s", dataStyle.Width(20).Align(lipgloss.Left).Render(stringTime()),
-		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%d", aveLog)),
-		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%d", ave)),
-		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%d", set.TestDataNum)),
-		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%d", cntNg)))
+	data := fmt.Sprintf("%s%s%s%s%s%s", dataStyle.Width(20).Align(lipgloss.Left).Render(stringTime()),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(formatMean(aveLog)),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(formatMean(ave)),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(caseCount),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%d", cntNg)),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%d", cntTle)))
+
+	// 実行時間とメモリ使用量
+	walls := make([]float64, 0)
+	maxCpu := time.Duration(0)
+	maxMem := int64(0)
+	maxJudge := time.Duration(0)
+	for i := 0; i < len(ri.stats); i++ {
+		if ri.stats[i].wall == 0 {
+			continue
+		}
+		walls = append(walls, float64(ri.stats[i].wall.Milliseconds()))
+		maxCpu = max(maxCpu, ri.stats[i].cpu)
+		maxMem = max(maxMem, ri.stats[i].maxRSS)
+		maxJudge = max(maxJudge, ri.stats[i].judgeWall)
+	}
+	header2 := fmt.Sprintf("%s%s%s%s", headerStyle.Width(20).Align(lipgloss.Left).Render("Max Time"),
+		headerStyle.Width(20).Align(lipgloss.Left).Render("P95 Time"),
+		headerStyle.Width(20).Align(lipgloss.Left).Render("Max CPU Time"),
+		headerStyle.Width(20).Align(lipgloss.Left).Render("Max Memory"))
+	data2 := fmt.Sprintf("%s%s%s%s", dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%.0fms", percentile(walls, 100))),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%.0fms", percentile(walls, 95))),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%dms", maxCpu.Milliseconds())),
+		dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%dKB", maxMem)))
+	if cmn.IsInteractive && cmn.NativeInteractive {
+		header2 += headerStyle.Width(20).Align(lipgloss.Left).Render("Max Judge Time")
+		data2 += dataStyle.Width(20).Align(lipgloss.Center).Render(fmt.Sprintf("%dms", maxJudge.Milliseconds()))
+	}
 
 	//fs
 	// 出力
 	if opt.quietMode {
-		fmt.Println(aveLog, ave, set.TestDataNum, cntNg)
+		fmt.Println(formatMean(aveLog), formatMean(ave), set.TestDataNum, cntNg, cntTle)
 	} else {
 		fmt.Println("")
+		if len(ri.logLabel) != 0 {
+			fmt.Println(headerStyle.Render(ri.logLabel))
+		}
 		fmt.Println(header)
 		fmt.Println(data)
+		fmt.Println("")
+		fmt.Println(headerStyle.Render("Verdicts") + "  " + verdictSummary(verdicts))
+		if len(walls) > 0 {
+			fmt.Println("")
+			fmt.Println(header2)
+			fmt.Println(data2)
+		}
+		if opt.loop > 1 {
+			fmt.Println("")
+			fmt.Println(trialSummary())
+		}
 		if len(fs) > 0 {
 			fmt.Println("")
 			fmt.Println("Error Cases")
 			fmt.Println(dataStyle.Width(80).Align(lipgloss.Left).Render(fmt.Sprintln(fs)))
 		}
+		if len(ts) > 0 {
+			fmt.Println("")
+			fmt.Println("TLE Cases")
+			fmt.Println(dataStyle.Width(80).Align(lipgloss.Left).Render(fmt.Sprintln(ts)))
+		}
 	}
 
-	now := stringTime()
 	if ri.enableLog {
-		runCsv := fmt.Sprintf("%s/%s", logs.logDir, RunCsv)
-		logLine := fmt.Sprintf("%s,%s,%d,%d,%d,%d,%d\n", now, opt.logMsg, cntOk, cntNg, tot, aveLog, ave)
-		writeToFile(runCsv, []byte(logLine), true)
-	}
-	if ri.enableLogStandings {
-		d := make([]int, 0)
-		for i := 0; i < set.TestDataNum; i++ {
-			d = append(d, ri.score[i].b)
-		}
-		historyCsv := fmt.Sprintf("%s/%s", logs.logDir, HistoryCsv)
-		counter := int64(0)
-		dat := intsToCsv(d)
-		if countLines(historyCsv) > 0 {
-			line := readLastLine(historyCsv)
-			lines := strings.Split(line, ",")
-			counter, _ = strconv.ParseInt(lines[1], 10, 64)
-			counter++
-		}
-		head := fmt.Sprintf("%s,%04d,%s,%s\n", now, counter, opt.logMsg, dat)
-		writeToFile(historyCsv, []byte(head), true)
-		if sd.Enable == true {
-			resultCSV := fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
-			l := fmt.Sprintf("%04d:%s,%s", counter, opt.logMsg, dat)
-			insertLine(resultCSV, 2, l)
+		summary := runSummary{Cases: set.TestDataNum, OK: cntOk, NG: cntNg + cntTle, TLE: cntTle, Sum: tot, GM: aveLog, AM: ave}
+		rec := newRunRecord(logMsg, summary, ri.enableLogStandings)
+		if ri.enableLogStandings {
+			rec.No = logs.nextNo
+			logs.nextNo++
+			rec.Git = saveGitState(ri.git, rec.No)
+		}
+		if err := appendRunRecord(rec); err != nil {
+			warningPrint("Failed to write %s: %v", runStorePath(), err)
+		}
+		// 途中までの結果は順位表の比較対象にしない
+		if ri.enableLogStandings && sd.Enable && !ri.interrupted {
+			logs.runs = append(logs.runs, rec)
+			resultCsv := fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
+			if err := exportResultCsv(resultCsv); err != nil {
+				warningPrint("Failed to write %s: %v", resultCsv, err)
+			}
 		}
 	}
+	// システムテストでは公式の順位表での推定順位を表示する
+	if ri.enableLogStandings && !ri.interrupted && !opt.quietMode {
+		printStanding(ri.score, opt.logMsg)
+	}
 }
 
 func runtimeInit() {
-	ri.score = make([]pair, set.TestDataNum)
+	ri.score = make([]caseScore, set.TestDataNum)
 
-	if len(opt.filter) == 0 && opt.loop == 1 {
+	if len(opt.filter) == 0 {
 		ri.enableLog = true
 	} else {
 		ri.enableLog = false
@@ -158,19 +340,49 @@ func runtimeInit() {
 
 	ri.lastDist = make([]int, 20)
 	ri.bestDist = make([]int, 20)
+	ri.tle = make([]bool, set.TestDataNum)
+	ri.stats = make([]caseStats, set.TestDataNum)
+	ri.done = make([]bool, set.TestDataNum)
+	ri.verdict = make([]string, set.TestDataNum)
+	ri.trials = make([][]caseScore, set.TestDataNum)
+	if len(opt.compare) != 0 {
+		ri.cmpTrials = make([][]caseScore, set.TestDataNum)
+		ri.cmpScore = make([]caseScore, set.TestDataNum)
+		ri.cmpTle = make([]bool, set.TestDataNum)
+		ri.cmpStats = make([]caseStats, set.TestDataNum)
+		ri.cmpDone = make([]bool, set.TestDataNum)
+		ri.cmpVerdict = make([]string, set.TestDataNum)
+	}
+	ri.caseLogs = caseLogsMode()
 }
 
 func workerPool() {
-	ri.score = make([]pair, set.TestDataNum)
 	ri.scoreSum = 0
 	ri.ng = make([]int, 0)
 
+	// Ctrl-C(SIGINT)/SIGTERMを受けたら新しいタスクの実行を止め、実行中のプロセスを終了させる
+	// hc watchではparentCtxのキャンセルでも同じように中断する
+	parent := context.Background()
+	if ri.parentCtx != nil {
+		parent = ri.parentCtx
+	}
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// --stop-if-worseで打ち切る場合もCtrl-Cと同じように中断する
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ri.ctx = ctx
+	ri.cancel = cancel
+
 	var mutex sync.Mutex
 	// ワーカーの数
 	numWorkers := cmn.Workers
 	ri.executingCase = make([]string, numWorkers)
 	// 実行するコマンドの総数
 	numCommands := len(ri.testID) * int(opt.loop)
+	if len(opt.compare) != 0 {
+		numCommands *= 2
+	}
 	//ri.lastDisplayTime = time.Now()
 	// タスク用のチャネル
 	tasks := make(chan string, numCommands)
@@ -186,8 +398,17 @@ func workerPool() {
 		progressbar.OptionEnableColorCodes(true),
 		progressbar.OptionShowElapsedTimeOnFinish(),
 		progressbar.OptionSetRenderBlankState(true),
+		progressbar.OptionSetPredictTime(false),
 		progressbar.OptionSetWriter(io.Discard))
 
+	// 時間のかかるケースが最後に残らないよう、過去の実行時間が長い順に実行する
+	ri.sched = newSchedule(ri.testID)
+	order := ri.sched.order(ri.testID)
+	ri.sched.tasks = numCommands
+	for _, id := range order {
+		ri.sched.total += ri.sched.cost[id] * float64(numCommands/max(len(order), 1))
+	}
+
 	if !opt.quietMode {
 		draw(&mutex)
 	}
@@ -197,9 +418,20 @@ func workerPool() {
 		go worker(i, &wg, tasks, &mutex)
 	}
 	for l := 1; l <= int(opt.loop); l++ {
-		for i := 0; i < len(ri.testID); i++ {
-			taskId := fmt.Sprintf("%04d %d", ri.testID[i], l)
-			tasks <- taskId
+		for i := 0; i < len(order); i++ {
+			taskId := fmt.Sprintf("%04d %d", order[i], l)
+			if len(opt.compare) == 0 {
+				tasks <- taskId
+				continue
+			}
+			// 比較モードでは負荷の偏りが出ないように実行順を交互に入れ替える
+			if i%2 == 0 {
+				tasks <- taskId
+				tasks <- taskId + " B"
+			} else {
+				tasks <- taskId + " B"
+				tasks <- taskId
+			}
 		}
 	}
 
@@ -207,6 +439,7 @@ func workerPool() {
 	close(tasks)
 	// 全ワーカーの処理完了を待つ
 	wg.Wait()
+	ri.interrupted = ctx.Err() != nil
 	if !opt.quietMode {
 		ri.lastDisplayTime = time.Now().Add(-24 * time.Hour)
 		draw(&mutex)
@@ -216,85 +449,117 @@ func workerPool() {
 func worker(id int, wg *sync.WaitGroup, tasks <-chan string, mutex *sync.Mutex) {
 	defer wg.Done()
 	for task := range tasks {
+		// 中断後は残りのタスクを実行しない
+		if runContext().Err() != nil {
+			continue
+		}
+		isCompare := len(strings.Fields(task)) == 3
+		trial, _ := strconv.Atoi(strings.Fields(task)[1])
 		task = strings.Fields(task)[0]
-		ri.executingCase[id] = task
 		idx, _ := strconv.Atoi(task)
+		if isCompare {
+			ri.executingCase[id] = task + "(B)"
+			liveUpdate(mutex, nil)
+			res := runTestCmd(task, trial, true)
+			if !res.canceled {
+				mutex.Lock()
+				ri.sched.done(idx, res.stats.wall)
+				mutex.Unlock()
+				applyCompareResult(idx, res, mutex)
+				if !opt.quietMode {
+					draw(mutex)
+				}
+				ri.executingCase[id] = ""
+				liveUpdate(mutex, newLiveCase(idx, id, res, true))
+			}
+			ri.executingCase[id] = ""
+			continue
+		}
+		ri.executingCase[id] = task
+		liveUpdate(mutex, nil)
 
-		sc, ok := runTestCmd(task)
-		if ok == false {
-			ri.ng = append(ri.ng, idx)
+		res := runTestCmd(task, trial, false)
+		if res.canceled {
+			ri.executingCase[id] = ""
+			continue
 		}
 		mutex.Lock()
+		if res.ok == false {
+			ri.ng = append(ri.ng, idx)
+		}
+		ri.done[idx] = true
+		if res.tle {
+			ri.tle[idx] = true
+		}
+		// 複数回実行した場合は最初に失敗した試行の判定結果を残す
+		if ri.verdict[idx] == "" || ri.verdict[idx] == VerdictAC {
+			ri.verdict[idx] = res.verdict
+		}
+		if res.stats.wall > ri.stats[idx].wall {
+			ri.stats[idx] = res.stats
+		}
+		if ri.checkpoint {
+			appendCheckpoint(idx, res)
+		}
 
-		ri.scoreSum += sc
-		if sc != 0 {
-			ri.scoreLogSum += math.Log(float64(sc))
+		if res.ok {
+			ri.scoreSum += res.score
+			ri.scoreCnt++
+			if res.score > 0 {
+				ri.scoreLogSum += math.Log(res.score)
+				ri.scoreLogCnt++
+			}
 		}
 		ri.executed++
+		ri.sched.done(idx, res.stats.wall)
 		ri.bar.Add(1)
+		ri.trials[idx] = append(ri.trials[idx], trialResult(res))
+		ri.score[idx] = trialScore(ri.trials[idx])
+		checkStopIfWorse()
 		mutex.Unlock()
-		ri.score[idx].a = idx
-		ri.score[idx].b = max(ri.score[idx].b, sc)
-
-		if ri.score[idx].b == 0 {
-			ri.score[idx].b = sc
-		} else {
-			if cmn.IsRankMin {
-				ri.score[idx].b = max(ri.score[idx].b, sc)
-			} else {
-				ri.score[idx].b = min(ri.score[idx].b, sc)
-			}
-		}
 
 		tid, _ := strconv.Atoi(task)
-		if !opt.quietMode {
+		if !opt.quietMode || ri.live != nil {
 			applyResult(id, tid, task, mutex)
+		}
+		if !opt.quietMode {
 			draw(mutex)
 		}
 		ri.executingCase[id] = ""
+		liveUpdate(mutex, newLiveCase(idx, id, res, false))
 	}
 }
+
 func applyResult(id int, tid int, task string, mutex *sync.Mutex) {
 	mutex.Lock()
 
-	if ri.score[tid].b <= 0 {
+	cur := ri.score[tid]
+	if !cur.valid() && ri.tle[tid] {
+		ri.tleCnt++
+		ri.tleTask = append(ri.tleTask, task)
+	} else if !cur.valid() {
 		ri.ngCnt++
 		ri.failedTask = append(ri.failedTask, task)
 	} else {
-		var f1, f2 float64
 		ri.okCnt++
-		if logs.last[tid] > 0 {
-			f1 = ((float64(ri.score[tid].b) - float64(logs.last[tid])) / float64(logs.last[tid])) * 100
-			if logs.last[tid] != INF {
-				if f1 > 0 {
-					ri.incLast = append(ri.incLast, scoreElem{ratio: f1, id: task, oldScore: logs.last[tid], newScore: ri.score[tid].b})
-				} else if f1 < 0 {
-					ri.decLast = append(ri.decLast, scoreElem{ratio: f1, id: task, oldScore: logs.last[tid], newScore: ri.score[tid].b})
-				}
-
+		f1, hasLast := scoreRatio(cur.v, logs.last[tid])
+		if hasLast {
+			if f1 > 0 {
+				ri.incLast = append(ri.incLast, scoreElem{ratio: f1, id: task, oldScore: logs.last[tid].v, newScore: cur.v})
+			} else if f1 < 0 {
+				ri.decLast = append(ri.decLast, scoreElem{ratio: f1, id: task, oldScore: logs.last[tid].v, newScore: cur.v})
 			}
 		}
+		best := logs.best
 		if set.IsSystemTest {
-			if logs.best2[tid] > 0 {
-				f2 = ((float64(ri.score[tid].b) - float64(logs.best2[tid])) / float64(logs.best2[tid])) * 100
-				if float64(logs.best2[tid]) != INF {
-					if f2 > 0 {
-						ri.incBest = append(ri.incBest, scoreElem{ratio: f2, id: task, oldScore: logs.best2[tid], newScore: ri.score[tid].b})
-					} else if f2 < 0 {
-						ri.decBest = append(ri.decBest, scoreElem{ratio: f2, id: task, oldScore: logs.best2[tid], newScore: ri.score[tid].b})
-					}
-				}
-			}
-		} else {
-			if logs.best[tid] > 0 {
-				f2 = ((float64(ri.score[tid].b) - float64(logs.best[tid])) / float64(logs.best[tid])) * 100
-				if float64(logs.best[tid]) != INF {
-					if f2 > 0 {
-						ri.incBest = append(ri.incBest, scoreElem{ratio: f2, id: task, oldScore: logs.best[tid], newScore: ri.score[tid].b})
-					} else if f2 < 0 {
-						ri.decBest = append(ri.decBest, scoreElem{ratio: f2, id: task, oldScore: logs.best[tid], newScore: ri.score[tid].b})
-					}
-				}
+			best = logs.best2
+		}
+		f2, hasBest := scoreRatio(cur.v, best[tid])
+		if hasBest {
+			if f2 > 0 {
+				ri.incBest = append(ri.incBest, scoreElem{ratio: f2, id: task, oldScore: best[tid].v, newScore: cur.v})
+			} else if f2 < 0 {
+				ri.decBest = append(ri.decBest, scoreElem{ratio: f2, id: task, oldScore: best[tid].v, newScore: cur.v})
 			}
 		}
 
@@ -326,70 +591,47 @@ func applyResult(id int, tid int, task string, mutex *sync.Mutex) {
 			ri.incBest = ri.incBest[:3]
 		}
 
-		if len(logs.last) != 0 && logs.last[tid] != INF {
-			if f1 < -160 {
-				ri.lastDist[0]++
-			} else if f1 < -80 {
-				ri.lastDist[1]++
-			} else if f1 < -40 {
-				ri.lastDist[2]++
-			} else if f1 < -20 {
-				ri.lastDist[3]++
-			} else if f1 < -10 {
-				ri.lastDist[4]++
-			} else if f1 < 0 {
-				ri.lastDist[5]++
-			} else if f1 == 0 {
-				ri.lastDist[6]++
-			} else if f1 <= 10 {
-				ri.lastDist[7]++
-			} else if f1 <= 20 {
-				ri.lastDist[8]++
-			} else if f1 <= 40 {
-				ri.lastDist[9]++
-			} else if f1 <= 80 {
-				ri.lastDist[10]++
-			} else if f1 <= 160 {
-				ri.lastDist[11]++
-			} else {
-				ri.lastDist[12]++
-			}
-		}
-
-		if len(logs.best) != 0 && logs.best[tid] != INF {
-			if f2 < -160 {
-				ri.bestDist[0]++
-			} else if f2 < -80 {
-				ri.bestDist[1]++
-			} else if f2 < -40 {
-				ri.bestDist[2]++
-			} else if f2 < -20 {
-				ri.bestDist[3]++
-			} else if f2 < -10 {
-				ri.bestDist[4]++
-			} else if f2 < 0.0 {
-				ri.bestDist[5]++
-			} else if f2 == 0 {
-				ri.bestDist[6]++
-			} else if f2 <= 10 {
-				ri.bestDist[7]++
-			} else if f2 <= 20 {
-				ri.bestDist[8]++
-			} else if f2 <= 40 {
-				ri.bestDist[9]++
-			} else if f2 <= 80 {
-				ri.bestDist[10]++
-			} else if f2 <= 160 {
-				ri.bestDist[11]++
-			} else if f2 > 160 {
-				ri.bestDist[12]++
-			}
+		if hasLast {
+			ri.lastDist[distIndex(f1)]++
+		}
+		if hasBest {
+			ri.bestDist[distIndex(f2)]++
 		}
-
 	}
 
 	mutex.Unlock()
 }
+
+// distIndex は変化率(%)が分布表のどの列に入るかを返します。
+func distIndex(f float64) int {
+	switch {
+	case f < -160:
+		return 0
+	case f < -80:
+		return 1
+	case f < -40:
+		return 2
+	case f < -20:
+		return 3
+	case f < -10:
+		return 4
+	case f < 0:
+		return 5
+	case f == 0:
+		return 6
+	case f <= 10:
+		return 7
+	case f <= 20:
+		return 8
+	case f <= 40:
+		return 9
+	case f <= 80:
+		return 10
+	case f <= 160:
+		return 11
+	}
+	return 12
+}
 func draw(mutex *sync.Mutex) {
 
 	mutex.Lock()
@@ -400,10 +642,11 @@ func draw(mutex *sync.Mutex) {
 	ri.lastDisplayTime = time.Now()
 
 	printLineBack(14)
-	fmt.Println(ri.bar.String())
+	fmt.Println(ri.bar.String() + etaString())
 	fmt.Println("")
 
 	var fsl string
+	var tsl string
 	var rsl string
 	var asl string
 
@@ -415,6 +658,11 @@ func draw(mutex *sync.Mutex) {
 	} else {
 		fsl = fmt.Sprintf("%d %v", ri.ngCnt, ri.failedTask)
 	}
+	if len(ri.tleTask) > 5 {
+		tsl = fmt.Sprintf("%d %v", ri.tleCnt, ri.tleTask[len(ri.tleTask)-5:])
+	} else {
+		tsl = fmt.Sprintf("%d %v", ri.tleCnt, ri.tleTask)
+	}
 	ec := make([]string, 0)
 	for i := 0; i < len(ri.executingCase); i++ {
 		if len(ri.executingCase[i]) != 0 {
@@ -427,35 +675,41 @@ func draw(mutex *sync.Mutex) {
 		ec = append(ec, "...")
 	}
 	rsl = fmt.Sprintf(" %v", ec)
-	ave := 0
-	aveLog := 0
-	if ri.okCnt != 0 {
-		ave = ri.scoreSum / ri.okCnt
-		aveLog = int(math.Round(math.Exp(ri.scoreLogSum / float64(ri.okCnt))))
+	ave := 0.0
+	aveLog := 0.0
+	if ri.scoreCnt != 0 {
+		ave = ri.scoreSum / float64(ri.scoreCnt)
 	}
-	asl = fmt.Sprintf("%d(%d)", aveLog, ave)
+	if ri.scoreLogCnt != 0 {
+		aveLog = math.Exp(ri.scoreLogSum / float64(ri.scoreLogCnt))
+	}
+	asl = fmt.Sprintf("%s(%s)", formatMean(aveLog), formatMean(ave))
 	title := lipgloss.NewStyle().Foreground(lipgloss.Color("7")).Width(40).Bold(true)
-	fmt.Printf("%-40s%-40s%-40s\n", title.Render("Mean"), title.Render("Failed"), title.Render("Running"))
-	fmt.Printf("%-40s%-40s%-40s\n", asl, fsl, rsl)
-	fmt.Println("")
+	fmt.Printf("%-40s%-40s%-40s%-40s\n", title.Render("Mean"), title.Render("Failed"), title.Render("TLE"), title.Render("Running"))
+	fmt.Printf("%-40s%-40s%-40s%-40s\n", asl, fsl, tsl, rsl)
+	if len(opt.compare) != 0 {
+		fmt.Println(headToHeadLine())
+	} else {
+		fmt.Println("")
+	}
 	sv := make([][]string, 4)
 	var t string
 
 	for i := 0; i < len(ri.decLast); i++ {
-		t = fmt.Sprintf("[%s] %d->%d(%3.2f%%)", ri.decLast[i].id, ri.decLast[i].oldScore, ri.decLast[i].newScore, ri.decLast[i].ratio)
+		t = fmt.Sprintf("[%s] %s->%s(%3.2f%%)", ri.decLast[i].id, ftoa(ri.decLast[i].oldScore), ftoa(ri.decLast[i].newScore), ri.decLast[i].ratio)
 		sv[0] = append(sv[0], t)
 	}
 	for i := 0; i < len(ri.decBest); i++ {
-		t = fmt.Sprintf("[%s] %d->%d(%3.2f%%)", ri.decBest[i].id, ri.decBest[i].oldScore, ri.decBest[i].newScore, ri.decBest[i].ratio)
+		t = fmt.Sprintf("[%s] %s->%s(%3.2f%%)", ri.decBest[i].id, ftoa(ri.decBest[i].oldScore), ftoa(ri.decBest[i].newScore), ri.decBest[i].ratio)
 		sv[1] = append(sv[1], t)
 	}
 
 	for i := 0; i < len(ri.incLast); i++ {
-		t = fmt.Sprintf("[%s] %d->%d(%3.2f%%)", ri.incLast[i].id, ri.incLast[i].oldScore, ri.incLast[i].newScore, ri.incLast[i].ratio)
+		t = fmt.Sprintf("[%s] %s->%s(%3.2f%%)", ri.incLast[i].id, ftoa(ri.incLast[i].oldScore), ftoa(ri.incLast[i].newScore), ri.incLast[i].ratio)
 		sv[2] = append(sv[2], t)
 	}
 	for i := 0; i < len(ri.incBest); i++ {
-		t = fmt.Sprintf("[%s] %d->%d(%3.2f%%)", ri.incBest[i].id, ri.incBest[i].oldScore, ri.incBest[i].newScore, ri.incBest[i].ratio)
+		t = fmt.Sprintf("[%s] %s->%s(%3.2f%%)", ri.incBest[i].id, ftoa(ri.incBest[i].oldScore), ftoa(ri.incBest[i].newScore), ri.incBest[i].ratio)
 		sv[3] = append(sv[3], t)
 	}
 	sp := lipgloss.NewStyle().Align(lipgloss.Left).Width
@@ -497,7 +751,18 @@ func draw(mutex *sync.Mutex) {
 	mutex.Unlock()
 }
 
-func runTestCmd(id string) (int, bool) {
+// runTestCmd はテストケースを1件実行します。trialは--loopでの試行番号(1始まり)で、isCompareがtrueの場合は--compareで指定したプログラムを実行します。
+func runTestCmd(id string, trial int, isCompare bool) caseResult {
+	var cl caseLogs
+	res := execTestCmd(id, trial, isCompare, &cl)
+	if !res.canceled {
+		saveCaseLogs(id, isCompare, res, cl)
+	}
+	return res
+}
+
+// execTestCmd はrunTestCmdの本体で、ソルバーの標準エラー出力とジャッジの出力をclに格納します。
+func execTestCmd(id string, trial int, isCompare bool, cl *caseLogs) caseResult {
 	if opt.debugMode {
 		debugPrint("runTestCmd started with id=%s", id)
 		debugPrint("TestDataPath=%s", set.TestDataPath)
@@ -531,7 +796,7 @@ func runTestCmd(id string) (int, bool) {
 		}
 		errorPrint("Input file not found")
 		fmt.Fprintf(os.Stderr, "  Absolute path: %s\n", absPath)
-		return 0, false
+		return caseResult{}
 	}
 
 	envErr := setEnvVar("INPUT_FILE", testFile)
@@ -539,20 +804,74 @@ func runTestCmd(id string) (int, bool) {
 		debugPrint("Error setting INPUT_FILE environment variable: %v", envErr)
 	}
 
-	var s []string
+	var stats caseStats
+	// 並列実行中でもケースごとに正しい値が渡るよう、子プロセスの環境変数として設定する
+	idx, _ := strconv.Atoi(id)
+	env := []string{
+		"INPUT_FILE=" + testFile,
+		fmt.Sprintf("HC_TRIAL=%d", trial),
+		fmt.Sprintf("HC_SEED=%d", trialSeed(idx, trial)),
+	}
+	targetProgram := cmn.TargetProgram
+	outSuffix := "_o"
+	if isCompare {
+		targetProgram = opt.compare
+		outSuffix = "_b_o"
+	}
+	src := scoreSources{id: id, in: testFile}
+	// 判定結果の材料(ジャッジがプログラムを起動する場合、プログラムの終了状態はわからない)
+	var solverErr, judgeErr error
+	var judgeOut string
 
-	if cmn.IsInteractive == true {
+	if cmn.IsInteractive && cmn.NativeInteractive {
+		if opt.debugMode {
+			debugPrint("Running native interactive mode")
+			debugPrint("JudgeProgram=%s", cmn.JudgeProgram)
+			debugPrint("TargetProgram=%s", targetProgram)
+		}
+		trSuffix := ""
+		if isCompare {
+			trSuffix = "_b"
+		}
+		trFile := fmt.Sprintf("%s/transcript/%s%s.txt", set.TestDataPath, id, trSuffix)
+		o1, o2, st, execErr, solverExit := executeInteractive(testFile, strings.Fields(cmn.JudgeProgram), strings.Fields(targetProgram), trFile, false, timeLimit(), env)
+		stats = st
+		cl.stderr, cl.judge = o2, o1
+		if execErr == errInterrupted {
+			return caseResult{canceled: true}
+		}
+		if execErr == errTimeLimitExceeded {
+			return caseResult{tle: true, stats: stats, verdict: VerdictTLE}
+		}
+		if opt.debugMode {
+			debugPrint("Native interactive exit code: %v", execErr)
+			if len(o2) > 0 {
+				debugPrint("First 100 chars of solver stderr: %s", truncString(o2, 100))
+			}
+		}
+		src.judge, src.stderr, src.exitErr = o1, o2, execErr
+		solverErr, judgeErr, judgeOut = solverExit, execErr, o1
+	} else if cmn.IsInteractive == true {
 		if opt.debugMode {
 			debugPrint("Running interactive mode")
 			debugPrint("JudgeProgram=%s", cmn.JudgeProgram)
-			debugPrint("TargetProgram=%s", cmn.TargetProgram)
+			debugPrint("TargetProgram=%s", targetProgram)
 		}
 		cmd := strings.Fields(cmn.JudgeProgram)
-		cmd = append(cmd, strings.Fields(cmn.TargetProgram)...)
+		cmd = append(cmd, strings.Fields(targetProgram)...)
 		if opt.debugMode {
 			debugPrint("Full command=%v", cmd)
 		}
-		o1, o2, exitCode := ExecuteWithFileInput(testFile, cmd, false, false)
+		o1, o2, st, exitCode := ExecuteWithFileInput(testFile, cmd, false, false, timeLimit(), env)
+		stats = st
+		// ジャッジがソルバーを起動するため、標準エラー出力は両者が混ざったものになる
+		cl.stderr = o2
+		if exitCode == errInterrupted {
+			return caseResult{canceled: true}
+		}
+		if exitCode == errTimeLimitExceeded {
+			return caseResult{tle: true, stats: stats, verdict: VerdictTLE}
+		}
 		if opt.debugMode {
 			debugPrint("Interactive command exit code: %v", exitCode)
 			if len(o1) > 0 {
@@ -562,17 +881,26 @@ func runTestCmd(id string) (int, bool) {
 				debugPrint("First 100 chars of stderr: %s", truncString(o2, 100))
 			}
 		}
-		s = strings.Split(string(o2), "\n")
+		src.judge, src.stdout, src.stderr, src.exitErr = o2, o1, o2, exitCode
+		judgeErr, judgeOut = exitCode, o2
 	} else {
 		if opt.debugMode {
 			debugPrint("Running non-interactive mode")
-			debugPrint("TargetProgram=%s", cmn.TargetProgram)
+			debugPrint("TargetProgram=%s", targetProgram)
 		}
-		cmd := strings.Fields(cmn.TargetProgram)
+		cmd := strings.Fields(targetProgram)
 		if opt.debugMode {
 			debugPrint("Target command=%v", cmd)
 		}
-		o1, o2, execErr := ExecuteWithFileInput(testFile, cmd, false, false)
+		o1, o2, st, execErr := ExecuteWithFileInput(testFile, cmd, false, false, timeLimit(), env)
+		stats = st
+		cl.stderr = o2
+		if execErr == errInterrupted {
+			return caseResult{canceled: true}
+		}
+		if execErr == errTimeLimitExceeded {
+			return caseResult{tle: true, stats: stats, verdict: VerdictTLE}
+		}
 		if opt.debugMode {
 			if execErr != nil {
 				debugPrint("Target command error: %v", execErr)
@@ -585,7 +913,7 @@ func runTestCmd(id string) (int, bool) {
 			}
 		}
 
-		tmpFile := fmt.Sprintf("%s/%s_o.txt", set.TestDataPath, id)
+		tmpFile := fmt.Sprintf("%s/%s%s.txt", set.TestDataPath, id, outSuffix)
 		if opt.debugMode {
 			debugPrint("Writing output to file: %s", tmpFile)
 		}
@@ -598,67 +926,42 @@ func runTestCmd(id string) (int, bool) {
 			debugPrint("JudgeProgram=%s", cmn.JudgeProgram)
 			debugPrint("Running judge command: %s %s %s", cmn.JudgeProgram, testFile, tmpFile)
 		}
-		o3, judgeErr := executeCommand([]string{cmn.JudgeProgram, testFile, tmpFile})
+		o3, jErr := executeJudge([]string{cmn.JudgeProgram, testFile, tmpFile}, timeLimit())
+		cl.judge = o3
+		if jErr == errInterrupted {
+			return caseResult{canceled: true}
+		}
+		// ジャッジが制限時間内に終わらない場合もTLEとする
+		if jErr == errTimeLimitExceeded {
+			return caseResult{tle: true, stats: stats, verdict: VerdictTLE}
+		}
 		if opt.debugMode {
-			if judgeErr != nil {
-				debugPrint("Judge command error: %v", judgeErr)
+			if jErr != nil {
+				debugPrint("Judge command error: %v", jErr)
 			}
 			if len(o3) > 0 {
-				debugPrint("First 100 chars of judge output: %s", truncString(string(o3), 100))
+				debugPrint("First 100 chars of judge output: %s", truncString(o3, 100))
 			}
 		}
-		s = strings.Split(string(o3), "\n")
+		src.judge, src.stdout, src.stderr, src.exitErr, src.out = o3, o1, o2, jErr, tmpFile
+		solverErr, judgeErr, judgeOut = execErr, jErr, o3
 	}
 
-	lineIdx := -1
-	for i := len(s) - 1; i >= 0; i-- {
-		if strings.HasPrefix(s[i], cmn.ScoreLine) {
-			lineIdx = i
-			break
-		}
-	}
-
-	if opt.debugMode {
-		debugPrint("Looking for score line with prefix '%s'", cmn.ScoreLine)
-		debugPrint("Score line index: %d", lineIdx)
+	sc, parseErr := parseScore(src)
+	if parseErr != nil {
+		warningPrint("Score not found: %v", parseErr)
 	}
-
-	// スコア行が見つからなかった場合のエラーハンドリング
-	if lineIdx == -1 {
-		warningPrint("Score line with prefix '%s' not found in output.", cmn.ScoreLine)
-		if opt.debugMode {
-			debugPrint("Output lines count: %d", len(s))
-			if len(s) > 0 {
-				debugPrint("Last few lines of output:")
-				start := max(0, len(s)-5)
-				for i := start; i < len(s); i++ {
-					debugPrint("[%d] %s", i, s[i])
-				}
-			}
+	v := judgeVerdict(stats, solverErr, judgeErr, judgeOut, parseErr)
+	if v != VerdictAC {
+		if opt.debugMode && v == VerdictRE {
+			debugPrint("Runtime error: %s", exitDetail(solverErr))
 		}
-		return 0, false
-	}
-
-	t := strings.Fields(s[lineIdx])
-	if opt.debugMode {
-		debugPrint("Score line: '%s'", s[lineIdx])
-		debugPrint("Score fields: %v", t)
-	}
-
-	if len(t) == 0 {
-		warningPrint("Empty score line found.")
-		return 0, false
+		return caseResult{stats: stats, verdict: v}
 	}
-
-	sc, parseErr := strconv.Atoi(t[len(t)-1])
 	if opt.debugMode {
-		if parseErr != nil {
-			debugPrint("Error parsing score: %v", parseErr)
-		} else {
-			debugPrint("Parsed score: %d", sc)
-		}
+		debugPrint("Parsed score: %s", ftoa(sc))
 	}
-	return sc, parseErr == nil
+	return caseResult{score: sc, ok: true, stats: stats, verdict: v}
 }
 func runSingleCmd(id string) {
 	if opt.debugMode {
@@ -705,10 +1008,39 @@ func runSingleCmd(id string) {
 		debugPrint("Error setting INPUT_FILE environment variable: %v", envErr)
 	}
 
-	var s []string
+	// hc runの各ケースと同じ環境変数をソルバーに渡す(-tは1回だけ実行するので試行番号は1)
+	idx, _ := strconv.Atoi(id)
+	env := []string{
+		"INPUT_FILE=" + testFile,
+		fmt.Sprintf("HC_TRIAL=%d", 1),
+		fmt.Sprintf("HC_SEED=%d", trialSeed(idx, 1)),
+	}
 	var o1, o2 string
 	var err error
-	if cmn.IsInteractive == true {
+	var stats caseStats
+	isTle := false
+	src := scoreSources{id: fmt.Sprintf("%04d", opt.target), in: testFile}
+	var solverErr, judgeErr error
+	var judgeOut string
+	// スコアを標準エラー出力から読み取る場合は、表示せずに取り込んでから表示する
+	displayStderr := scoreSource() != ScoreSourceStderr
+	if cmn.IsInteractive && cmn.NativeInteractive {
+		if opt.debugMode {
+			debugPrint("Running native interactive mode")
+			debugPrint("JudgeProgram=%s", cmn.JudgeProgram)
+			debugPrint("TargetProgram=%s", cmn.TargetProgram)
+		}
+		trFile := fmt.Sprintf("%s/transcript.txt", previousDirectory)
+		o1, o2, stats, err, solverErr = executeInteractive(testFile, strings.Fields(cmn.JudgeProgram), strings.Fields(cmn.TargetProgram), trFile, displayStderr, timeLimit(), env)
+		if err == errTimeLimitExceeded {
+			isTle = true
+		}
+		if opt.debugMode && err != nil {
+			debugPrint("Native interactive exit code: %v", err)
+		}
+		src.judge, src.stderr, src.exitErr = o1, o2, err
+		judgeErr, judgeOut = err, o1
+	} else if cmn.IsInteractive == true {
 		if opt.debugMode {
 			debugPrint("Running interactive mode")
 			debugPrint("JudgeProgram=%s", cmn.JudgeProgram)
@@ -719,7 +1051,11 @@ func runSingleCmd(id string) {
 		if opt.debugMode {
 			debugPrint("Full command=%v", cmd)
 		}
-		o1, o2, exitCode := ExecuteWithFileInput(testFile, cmd, false, false)
+		o1, o2, st, exitCode := ExecuteWithFileInput(testFile, cmd, false, false, timeLimit(), env)
+		stats = st
+		if exitCode == errTimeLimitExceeded {
+			isTle = true
+		}
 		if opt.debugMode {
 			debugPrint("Interactive command exit code: %v", exitCode)
 			if len(o1) > 0 {
@@ -729,8 +1065,8 @@ func runSingleCmd(id string) {
 				debugPrint("First 100 chars of stderr: %s", truncString(o2, 100))
 			}
 		}
-		_ = o1
-		s = strings.Split(string(o2), "\n")
+		src.judge, src.stdout, src.stderr, src.exitErr = o2, o1, o2, exitCode
+		judgeErr, judgeOut = exitCode, o2
 
 	} else {
 		if opt.debugMode {
@@ -741,7 +1077,10 @@ func runSingleCmd(id string) {
 		if opt.debugMode {
 			debugPrint("Target command=%v", cmd)
 		}
-		o1, o2, err = ExecuteWithFileInput(testFile, cmd, false, true)
+		o1, o2, stats, err = ExecuteWithFileInput(testFile, cmd, false, displayStderr, timeLimit(), env)
+		if err == errTimeLimitExceeded {
+			isTle = true
+		}
 		if opt.debugMode {
 			if err != nil {
 				debugPrint("Target command error: %v", err)
@@ -767,69 +1106,42 @@ func runSingleCmd(id string) {
 			debugPrint("JudgeProgram=%s", cmn.JudgeProgram)
 			debugPrint("Running judge command: %s %s %s", cmn.JudgeProgram, testFile, tmpFile)
 		}
-		o3, judgeErr := executeCommand([]string{cmn.JudgeProgram, testFile, tmpFile})
+		o3, jErr := executeJudge([]string{cmn.JudgeProgram, testFile, tmpFile}, timeLimit())
+		if jErr == errTimeLimitExceeded {
+			isTle = true
+		}
 		if opt.debugMode {
-			if judgeErr != nil {
-				debugPrint("Judge command error: %v", judgeErr)
+			if jErr != nil {
+				debugPrint("Judge command error: %v", jErr)
 			}
 			if len(o3) > 0 {
-				debugPrint("First 100 chars of judge output: %s", truncString(string(o3), 100))
+				debugPrint("First 100 chars of judge output: %s", truncString(o3, 100))
 			}
 		}
-		s = strings.Split(string(o3), "\n")
+		src.judge, src.stdout, src.stderr, src.exitErr, src.out = o3, o1, o2, jErr, tmpFile
+		solverErr, judgeErr, judgeOut = err, jErr, o3
 	}
-	lineIdx := -1
-	for i := len(s) - 1; i >= 0; i-- {
-		if strings.HasPrefix(s[i], cmn.ScoreLine) {
-			lineIdx = i
-			break
-		}
+	if !displayStderr {
+		fmt.Fprint(os.Stderr, o2)
 	}
-
-	if opt.debugMode {
-		debugPrint("Looking for score line with prefix '%s'", cmn.ScoreLine)
-		debugPrint("Score line index: %d", lineIdx)
+	if runContext().Err() != nil {
+		warningPrint("Interrupted")
+		return
 	}
-
-	// スコア行が見つからなかった場合のエラーハンドリング
-	sc := 0
-	var parseErr error
-	if lineIdx == -1 {
-		warningPrint("Score line with prefix '%s' not found in output.", cmn.ScoreLine)
-		if opt.debugMode {
-			debugPrint("Output lines count: %d", len(s))
-			if len(s) > 0 {
-				debugPrint("Last few lines of output:")
-				start := max(0, len(s)-5)
-				for i := start; i < len(s); i++ {
-					debugPrint("[%d] %s", i, s[i])
-				}
-			}
-		}
-		parseErr = fmt.Errorf("score line not found")
-	} else {
-		t := strings.Fields(s[lineIdx])
+	v, parseErr := parseScore(src)
+	sc := caseScore{st: scoreFailed}
+	if parseErr != nil {
+		warningPrint("Score not found: %v", parseErr)
+	}
+	verdict := judgeVerdict(stats, solverErr, judgeErr, judgeOut, parseErr)
+	if verdict == VerdictAC {
+		sc = okScore(v)
 		if opt.debugMode {
-			debugPrint("Score line: '%s'", s[lineIdx])
-			debugPrint("Score fields: %v", t)
-		}
-		if len(t) == 0 {
-			warningPrint("Empty score line found.")
-			parseErr = fmt.Errorf("empty score line")
-		} else {
-			sc, parseErr = strconv.Atoi(t[len(t)-1])
-			if opt.debugMode {
-				if parseErr != nil {
-					debugPrint("Error parsing score: %v", parseErr)
-				} else {
-					debugPrint("Parsed score: %d", sc)
-				}
-			}
+			debugPrint("Parsed score: %s", ftoa(v))
 		}
 	}
 
-	idx, _ := strconv.Atoi(id)
-	if parseErr != nil {
+	if verdict != VerdictAC {
 		ri.ng = append(ri.ng, idx)
 		if opt.debugMode {
 			debugPrint("Adding to NG list: %d", idx)
@@ -840,14 +1152,29 @@ func runSingleCmd(id string) {
 		if opt.debugMode {
 			debugPrint("System test mode, using best2 values")
 		}
-		fmt.Printf("No=%04d Score=%d  Best=%d Rank=%d/%d\n", opt.target, sc, logs.best2[opt.target], calcRank(sc, int(opt.target)), len(logs.vals2)+1)
+		fmt.Printf("No=%04d Score=%s  Best=%s Rank=%d/%d\n", opt.target, formatScore(sc), formatScore(logs.best2[opt.target]), calcRank(sc, int(opt.target)), len(logs.vals2)+1)
 	} else {
 		if opt.debugMode {
 			debugPrint("Normal mode, using best values")
 		}
-		fmt.Printf("No=%04d Score=%d  Best=%d Rank=%d/%d\n", opt.target, sc, logs.best[opt.target], calcRank(sc, int(opt.target)), len(logs.vals)+1)
+		fmt.Printf("No=%04d Score=%s  Best=%s Rank=%d/%d\n", opt.target, formatScore(sc), formatScore(logs.best[opt.target]), calcRank(sc, int(opt.target)), len(logs.vals)+1)
 	}
 
+	if isTle {
+		warningPrint("Time limit exceeded (%v)", timeLimit())
+	}
+	switch verdict {
+	case VerdictRE:
+		fmt.Printf("Verdict=%s (%s)\n", verdict, exitDetail(solverErr))
+	case VerdictMLE:
+		fmt.Printf("Verdict=%s (limit %dKB)\n", verdict, memoryLimitKB())
+	default:
+		fmt.Printf("Verdict=%s\n", verdict)
+	}
+	fmt.Printf("Time=%dms CPU=%dms Memory=%dKB\n", stats.wall.Milliseconds(), stats.cpu.Milliseconds(), stats.maxRSS)
+	if cmn.IsInteractive && cmn.NativeInteractive {
+		fmt.Printf("JudgeTime=%dms JudgeCPU=%dms\n", stats.judgeWall.Milliseconds(), stats.judgeCpu.Milliseconds())
+	}
 	if len(set.Seeds) != 0 {
 		fmt.Printf("Parameter=%s Seed=%s\n", hi.HeaderData[opt.target], set.Seeds[opt.target])
 	} else {
@@ -862,9 +1189,22 @@ func init() {
 	runCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
 	runCmd.Flags().BoolVarP(&opt.quietMode, "quiet", "q", false, "Run in quiet mode")
 	runCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
-	runCmd.Flags().IntVarP(&opt.loop, "loop", "l", 1, "Set filter definition")
+	runCmd.Flags().IntVarP(&opt.loop, "loop", "l", 1, "Run each test case the given number of times")
 	runCmd.Flags().IntVarP(&opt.target, "target", "t", -1, "Set filter definition")
 	runCmd.Flags().StringVarP(&opt.logMsg, "write-log", "w", "", "log & comment")
 	runCmd.Flags().BoolVarP(&opt.debugMode, "debug", "x", false, "Enable debug output")
+	runCmd.Flags().StringVar(&opt.compare, "compare", "", "Run another target program on every case and compare the results")
+	runCmd.Flags().BoolVar(&opt.resume, "resume", false, "Resume the interrupted run from the checkpoint")
+	runCmd.Flags().BoolVar(&opt.savePartial, "save-partial", false, "Save the completed results to the log when interrupted")
+	runCmd.Flags().Float64Var(&opt.timeLimit, "time-limit", 0, "Time limit per test case in seconds (0: no limit)")
+	runCmd.Flags().IntVar(&opt.memoryLimit, "memory-limit", 0, "Memory limit per test case in MB (0: no limit)")
+	runCmd.Flags().BoolVar(&opt.noBuild, "no-build", false, "Do not run BuildCmd before the run")
+	runCmd.Flags().BoolVar(&opt.rebuild, "rebuild", false, "Run BuildCmd even if the source files have not changed")
+	runCmd.Flags().Float64Var(&opt.stopIfWorse, "stop-if-worse", 0, "Stop the run when it is clearly worse than --stop-ref by more than this percentage (0: never)")
+	runCmd.Flags().StringVar(&opt.stopRef, "stop-ref", "best", "Reference for --stop-if-worse (best or last)")
+	runCmd.Flags().BoolVar(&opt.web, "web", false, "Show the progress of the run in the browser")
+	runCmd.Flags().IntVar(&opt.webPort, "port", DefaultWebPort, "Port of the web server for --web (a free port is used if it is in use)")
+	runCmd.Flags().StringVar(&opt.webHost, "host", "", "Host address of the web server for --web (empty: all interfaces)")
+	runCmd.Flags().StringVar(&opt.caseLogs, "case-logs", "", "Which cases keep their stderr and judge output (all, failures, none)")
 
 }
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// schedule はケースの実行順と残り時間の見積もりです。
+// costは過去の実行時間(ms)で、記録がない場合は入力ファイルのサイズから見積もります。
+type schedule struct {
+	cost     []float64 // ケースごとの見積もり
+	timed    bool      // costがすべて実行時間(ms)か(falseの場合は単位がない)
+	total    float64   // 全タスクの見積もりの合計
+	doneEst  float64   // 終わったタスクの見積もりの合計
+	doneWall time.Duration
+	tasks    int // タスクの数
+	finished int // 終わったタスクの数
+}
+
+// lastWalls は各ケースについて最も新しい実行時間の記録を返します。記録がなければ0です。
+func lastWalls() []time.Duration {
+	walls := make([]time.Duration, set.TestDataNum)
+	found := 0
+	for i := len(logs.runs) - 1; i >= 0 && found < set.TestDataNum; i-- {
+		st := logs.stats[logs.runs[i].No]
+		for j := 0; j < len(st) && j < set.TestDataNum; j++ {
+			if walls[j] == 0 && st[j].wall != 0 {
+				walls[j] = st[j].wall
+				found++
+			}
+		}
+	}
+	return walls
+}
+
+// inputSize は入力ファイルのサイズを返します。
+func inputSize(id int) float64 {
+	for _, f := range []string{fmt.Sprintf("%s/in/%04d.txt", set.TestDataPath, id), fmt.Sprintf("%s/%04d.txt", set.TestDataPath, id)} {
+		if fi, err := os.Stat(f); err == nil {
+			return float64(fi.Size())
+		}
+	}
+	return 0
+}
+
+// newSchedule はケースごとの見積もりを作ります。実行時間の記録がないケースは、
+// 記録があるケースの「実行時間/入力サイズ」の中央値を使って入力サイズから見積もります。
+func newSchedule(ids []int) *schedule {
+	s := &schedule{cost: make([]float64, set.TestDataNum), timed: true}
+	walls := lastWalls()
+	sizes := make([]float64, set.TestDataNum)
+	rates := make([]float64, 0)
+	missing := false
+	for _, id := range ids {
+		sizes[id] = inputSize(id)
+		if walls[id] == 0 {
+			missing = true
+			continue
+		}
+		s.cost[id] = float64(walls[id].Milliseconds())
+		if sizes[id] > 0 {
+			rates = append(rates, s.cost[id]/sizes[id])
+		}
+	}
+	if !missing {
+		return s
+	}
+	rate := 1.0
+	if len(rates) > 0 {
+		sort.Float64s(rates)
+		rate = rates[len(rates)/2]
+	} else {
+		// 実行時間の記録がまったくない場合は入力サイズの順に並べるだけにする
+		s.timed = false
+	}
+	for _, id := range ids {
+		if walls[id] == 0 {
+			s.cost[id] = sizes[id] * rate
+		}
+	}
+	return s
+}
+
+// order はidsを見積もりの大きい順に並べ替えたものを返します。見積もりが同じ場合は元の順序のままです。
+func (s *schedule) order(ids []int) []int {
+	o := make([]int, len(ids))
+	copy(o, ids)
+	sort.SliceStable(o, func(i, j int) bool { return s.cost[o[i]] > s.cost[o[j]] })
+	return o
+}
+
+// done は終わったタスクを記録します。呼び出し側でロックしてください。
+func (s *schedule) done(id int, wall time.Duration) {
+	s.doneEst += s.cost[id]
+	s.doneWall += wall
+	s.finished++
+}
+
+// eta は残り時間の見積もりを返します。見積もれない場合はfalseを返します。
+// 終わったタスクの実際の時間と見積もりの比で補正し、ワーカーの数で割ります。
+func (s *schedule) eta(workers int) (time.Duration, bool) {
+	if s.finished >= s.tasks {
+		return 0, false
+	}
+	var perUnit float64
+	switch {
+	case s.doneEst > 0 && s.doneWall > 0:
+		perUnit = float64(s.doneWall) / s.doneEst
+	case s.timed:
+		perUnit = float64(time.Millisecond)
+	default:
+		return 0, false
+	}
+	remain := max(s.total-s.doneEst, 0) * perUnit / float64(max(workers, 1))
+	return time.Duration(remain), true
+}
+
+// etaString は進捗表示に付ける残り時間です。
+func etaString() string {
+	if ri.sched == nil {
+		return ""
+	}
+	d, ok := ri.sched.eta(cmn.Workers)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("  ETA %s", d.Round(time.Second))
+}
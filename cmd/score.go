@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// スコアの読み取り元
+const (
+	ScoreSourceJudge    = "judge"    // ジャッジの出力(インタラクティブの場合は標準エラー出力)
+	ScoreSourceStdout   = "stdout"   // プログラム(インタラクティブの場合はジャッジ)の標準出力
+	ScoreSourceStderr   = "stderr"   // プログラムの標準エラー出力
+	ScoreSourceFile     = "file"     // [score]のFileで指定したファイル
+	ScoreSourceExitCode = "exitcode" // ジャッジの終了コード
+)
+
+// スコアの抽出方法
+const (
+	ScoreExtractorLine  = "line"  // Pattern(省略時はScoreLine)で始まる最後の行の最後の値
+	ScoreExtractorRegex = "regex" // Patternの正規表現に最後にマッチした箇所
+	ScoreExtractorJson  = "json"  // PatternのJSONPathで指定した値
+)
+
+// scoreSources は1ケースの実行で得られた、スコアの読み取り元になりうる出力です。
+type scoreSources struct {
+	judge   string
+	stdout  string
+	stderr  string
+	exitErr error
+	id      string
+	in      string
+	out     string
+}
+
+var scoreRegexp struct {
+	once sync.Once
+	re   *regexp.Regexp
+	err  error
+}
+
+// scoreSource は[score]のSourceを返します。未指定の場合はjudgeです。
+func scoreSource() string {
+	if scr.Source == "" {
+		return ScoreSourceJudge
+	}
+	return scr.Source
+}
+
+// scoreExtractor は[score]のExtractorを返します。未指定の場合はlineです。
+func scoreExtractor() string {
+	if scr.Extractor == "" {
+		return ScoreExtractorLine
+	}
+	return scr.Extractor
+}
+
+// parseScore は[score]の設定に従って実行結果からスコアを読み取ります。
+func parseScore(src scoreSources) (float64, error) {
+	var text string
+	switch scoreSource() {
+	case ScoreSourceJudge:
+		text = src.judge
+	case ScoreSourceStdout:
+		text = src.stdout
+	case ScoreSourceStderr:
+		text = src.stderr
+	case ScoreSourceFile:
+		f := scoreFilePath(src)
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the score file: %v", err)
+		}
+		text = string(b)
+	case ScoreSourceExitCode:
+		var ee *exec.ExitError
+		if errors.As(src.exitErr, &ee) {
+			return float64(ee.ExitCode()), nil
+		}
+		if src.exitErr != nil {
+			return 0, src.exitErr
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unknown score source '%s'", scr.Source)
+	}
+	return extractScore(text)
+}
+
+// scoreFilePath は[score]のFileの{id}、{set}、{in}、{out}を置き換えたパスを返します。
+func scoreFilePath(src scoreSources) string {
+	r := strings.NewReplacer("{id}", src.id, "{set}", set.TestDataPath, "{in}", src.in, "{out}", src.out)
+	return r.Replace(scr.File)
+}
+
+// extractScore は[score]のExtractorとPatternに従ってテキストからスコアを取り出します。
+func extractScore(text string) (float64, error) {
+	switch scoreExtractor() {
+	case ScoreExtractorLine:
+		prefix := cmn.ScoreLine
+		if scr.Pattern != "" {
+			prefix = scr.Pattern
+		}
+		s := strings.Split(text, "\n")
+		for i := len(s) - 1; i >= 0; i-- {
+			t := strings.Fields(s[i])
+			if len(t) == 0 || !strings.HasPrefix(s[i], prefix) {
+				continue
+			}
+			return parseScoreValue(t[len(t)-1])
+		}
+		return 0, fmt.Errorf("score line with prefix '%s' not found", prefix)
+	case ScoreExtractorRegex:
+		scoreRegexp.once.Do(func() {
+			scoreRegexp.re, scoreRegexp.err = regexp.Compile(scr.Pattern)
+		})
+		if scoreRegexp.err != nil {
+			return 0, fmt.Errorf("invalid score pattern: %v", scoreRegexp.err)
+		}
+		re := scoreRegexp.re
+		m := re.FindAllStringSubmatch(text, -1)
+		if len(m) == 0 {
+			return 0, fmt.Errorf("score pattern '%s' not matched", scr.Pattern)
+		}
+		last := m[len(m)-1]
+		// 名前付きグループscore、最初のグループ、マッチ全体の順に使う
+		if k := re.SubexpIndex("score"); k >= 0 {
+			return parseScoreValue(last[k])
+		}
+		if len(last) > 1 {
+			return parseScoreValue(last[1])
+		}
+		return parseScoreValue(last[0])
+	case ScoreExtractorJson:
+		v, err := findJson(text, scr.Pattern)
+		if err != nil {
+			return 0, err
+		}
+		return parseScoreValue(v)
+	}
+	return 0, fmt.Errorf("unknown score extractor '%s'", scr.Extractor)
+}
+
+// parseScoreValue は取り出したスコアの文字列を数値にします。
+func parseScoreValue(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+// findJson はテキスト全体、またはJSONとして読める最後の行からpathの値を文字列で返します。
+func findJson(text string, path string) (string, error) {
+	var doc interface{}
+	if err := decodeJson(text, &doc); err != nil {
+		found := false
+		s := strings.Split(strings.TrimSpace(text), "\n")
+		for i := len(s) - 1; i >= 0; i-- {
+			if decodeJson(s[i], &doc) == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("JSON not found in output")
+		}
+	}
+	v, err := evalJsonPath(doc, path)
+	if err != nil {
+		return "", err
+	}
+	switch t := v.(type) {
+	case json.Number:
+		return t.String(), nil
+	case string:
+		return t, nil
+	}
+	return "", fmt.Errorf("value at '%s' is not a number", path)
+}
+
+func decodeJson(s string, v *interface{}) error {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// evalJsonPath はJSONPathのうち $、.key、['key']、[n](負の値は末尾から)だけに対応した簡易版です。
+func evalJsonPath(doc interface{}, path string) (interface{}, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	v := doc
+	for len(p) > 0 {
+		var key string
+		idx, isIdx := 0, false
+		switch {
+		case p[0] == '.':
+			p = p[1:]
+			n := strings.IndexAny(p, ".[")
+			if n < 0 {
+				n = len(p)
+			}
+			key, p = p[:n], p[n:]
+		case p[0] == '[':
+			n := strings.Index(p, "]")
+			if n < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+			}
+			in := p[1:n]
+			p = p[n+1:]
+			if len(in) >= 2 && (in[0] == '\'' || in[0] == '"') && in[len(in)-1] == in[0] {
+				key = in[1 : len(in)-1]
+			} else {
+				i, err := strconv.Atoi(in)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+				}
+				idx, isIdx = i, true
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+		}
+		if isIdx {
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'%s' not found", path)
+			}
+			if idx < 0 {
+				idx += len(a)
+			}
+			if idx < 0 || idx >= len(a) {
+				return nil, fmt.Errorf("'%s' not found", path)
+			}
+			v = a[idx]
+			continue
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%s' not found", path)
+		}
+		if v, ok = m[key]; !ok {
+			return nil, fmt.Errorf("'%s' not found", path)
+		}
+	}
+	return v, nil
+}
+
+// okScore はスコアvが得られたことを表すcaseScoreを返します。
+func okScore(v float64) caseScore {
+	return caseScore{v: v, st: scoreOK}
+}
+
+// valid はスコアが得られているかを返します。
+func (s caseScore) valid() bool {
+	return s.st == scoreOK
+}
+
+// isBetter はスコアaがbより良いかを返します(IsRankMinの場合は小さい方が良い)。
+func isBetter(a, b float64) bool {
+	if cmn.IsRankMin {
+		return a < b
+	}
+	return a > b
+}
+
+// updateBest はbestよりsが良ければsを返します。
+func updateBest(best, s caseScore) caseScore {
+	if s.valid() && (!best.valid() || isBetter(s.v, best.v)) {
+		return s
+	}
+	return best
+}
+
+// scoreRatio は基準oldに対するnewの変化率(%)を返します。oldのスコアがない場合や0の場合はfalseを返します。
+func scoreRatio(new float64, old caseScore) (float64, bool) {
+	if !old.valid() || old.v == 0 {
+		return 0, false
+	}
+	return (new - old.v) / math.Abs(old.v) * 100, true
+}
+
+// calcAverage はスコアが得られたケースの相乗平均と相加平均、失敗したケースの数を返します。
+// 相乗平均は正のスコアだけで計算します。
+func calcAverage(a []caseScore) (gm float64, am float64, ng int) {
+	ls, s := 0.0, 0.0
+	ok, pos := 0, 0
+	for i := 0; i < len(a); i++ {
+		switch a[i].st {
+		case scoreFailed:
+			ng++
+		case scoreOK:
+			ok++
+			s += a[i].v
+			if a[i].v > 0 {
+				pos++
+				ls += math.Log(a[i].v)
+			}
+		}
+	}
+	if ok > 0 {
+		am = s / float64(ok)
+	}
+	if pos > 0 {
+		gm = math.Exp(ls / float64(pos))
+	}
+	return gm, am, ng
+}
+
+// ftoa はスコアを表示用の文字列にします(小数点以下は6桁まで)。
+func ftoa(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 6, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// formatScore はケースのスコアを表示用の文字列にします。失敗は「F」、未実行は「-」です。
+func formatScore(s caseScore) string {
+	switch s.st {
+	case scoreOK:
+		return ftoa(s.v)
+	case scoreFailed:
+		return "F"
+	}
+	return "-"
+}
+
+// formatMean は平均値を表示用の文字列にします。大きな値は整数に丸め、小さな値は小数点以下2桁まで表示します。
+func formatMean(v float64) string {
+	if math.Abs(v) >= 1000 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// scoresToCsv はスコアをチェックポイントの形式に変換します。失敗は「F」、未実行は空欄です。
+func scoresToCsv(a []caseScore) string {
+	t := make([]byte, 0)
+	for i := 0; i < len(a); i++ {
+		switch a[i].st {
+		case scoreOK:
+			t = strconv.AppendFloat(t, a[i].v, 'f', -1, 64)
+		case scoreFailed:
+			t = append(t, 'F')
+		}
+		t = append(t, ',')
+	}
+	return string(t)
+}
+
+// parseScoreField はscoresToCsvで出力した1要素を読み込みます。
+func parseScoreField(s string) caseScore {
+	if s == "F" {
+		return caseScore{st: scoreFailed}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return caseScore{}
+	}
+	return okScore(v)
+}
+
+// parseLegacyScoreField はhistory.csvの1要素(正の整数、-1が失敗、0が未実行)を読み込みます。
+func parseLegacyScoreField(s string) caseScore {
+	v, _ := strconv.Atoi(s)
+	if v > 0 {
+		return okScore(float64(v))
+	} else if v < 0 {
+		return caseScore{st: scoreFailed}
+	}
+	return caseScore{}
+}
+
+// resultsToCsv はスコアをresult.csv(順位表)の形式に変換します。順位表では0がスコアなしを表します。
+func resultsToCsv(a []caseScore) string {
+	t := make([]byte, 0)
+	for i := 0; i < len(a); i++ {
+		if a[i].valid() {
+			t = strconv.AppendFloat(t, a[i].v, 'f', -1, 64)
+		} else {
+			t = append(t, '0')
+		}
+		t = append(t, ',')
+	}
+	return string(t)
+}
+
+// parseResultField はresult.csvの1要素を読み込みます。
+func parseResultField(s string) caseScore {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v == 0 {
+		return caseScore{st: scoreFailed}
+	}
+	return okScore(v)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// 2つの実行の対応のある比較(hc log diff)
+//
+// ケースごとにスコアの対数比 ln(B/A) を求め(IsRankMinの場合は符号を反転し、正がBの改善)、
+// 勝ち負けの数、平均のブートストラップ信頼区間、Wilcoxonの符号順位検定のp値を出す。
+
+const (
+	bootstrapRounds = 2000
+	bootstrapSeed   = 1 // 同じ入力で同じ結果になるよう固定する
+)
+
+// pairedResult はAに対するBの比較結果です。
+type pairedResult struct {
+	win, lose, tie int     // Bが良い、悪い、同じケースの数(片方だけ失敗したケースを含む)
+	failA, failB   int     // 片方だけ失敗したケースの数
+	n              int     // 対数比を計算できたケースの数
+	mean           float64 // 対数比の平均
+	lo, hi         float64 // 平均の95%信頼区間
+	p              float64 // Wilcoxonの符号順位検定のp値(両側)
+}
+
+// pairedAnalysis はAとBのスコアを比較します。
+func pairedAnalysis(a, b []caseScore) pairedResult {
+	var res pairedResult
+	rs := make([]float64, 0, len(a))
+	for i := range a {
+		switch {
+		case a[i].st == scoreNone || b[i].st == scoreNone:
+			// どちらかで実行していないケースは比較しない
+			continue
+		case a[i].st == scoreFailed && b[i].st == scoreFailed:
+			continue
+		case a[i].st == scoreFailed:
+			res.failA++
+			res.win++
+			continue
+		case b[i].st == scoreFailed:
+			res.failB++
+			res.lose++
+			continue
+		}
+		if a[i].v == b[i].v {
+			res.tie++
+		} else if isBetter(b[i].v, a[i].v) {
+			res.win++
+		} else {
+			res.lose++
+		}
+		if r, ok := logRatio(b[i], a[i]); ok {
+			rs = append(rs, r)
+		}
+	}
+	res.n = len(rs)
+	if res.n == 0 {
+		res.p = 1
+		return res
+	}
+	res.mean = meanOf(rs)
+	res.lo, res.hi = bootstrapCI(rs, 0.95)
+	res.p = wilcoxonP(rs)
+	return res
+}
+
+// logRatio はスコアの対数比 ln(cur/ref) を返します。IsRankMinの場合も正の値が良い方向です。
+// 片方が失敗しているか正でない場合は比較できないためfalseを返します。
+func logRatio(cur, ref caseScore) (float64, bool) {
+	if !cur.valid() || !ref.valid() || cur.v <= 0 || ref.v <= 0 {
+		return 0, false
+	}
+	r := math.Log(cur.v / ref.v)
+	if cmn.IsRankMin {
+		r = -r
+	}
+	return r, true
+}
+
+func meanOf(a []float64) float64 {
+	s := 0.0
+	for _, v := range a {
+		s += v
+	}
+	return s / float64(len(a))
+}
+
+// bootstrapCI は平均のブートストラップ信頼区間(パーセンタイル法)を返します。
+func bootstrapCI(a []float64, level float64) (float64, float64) {
+	rng := rand.New(rand.NewSource(bootstrapSeed))
+	means := make([]float64, bootstrapRounds)
+	for k := range means {
+		s := 0.0
+		for range a {
+			s += a[rng.Intn(len(a))]
+		}
+		means[k] = s / float64(len(a))
+	}
+	alpha := (1 - level) / 2 * 100
+	return percentile(means, alpha), percentile(means, 100-alpha)
+}
+
+// wilcoxonP はWilcoxonの符号順位検定の両側p値を正規近似で返します。0の差は除き、同順位は平均順位とします。
+func wilcoxonP(a []float64) float64 {
+	d := make([]float64, 0, len(a))
+	for _, v := range a {
+		if v != 0 {
+			d = append(d, v)
+		}
+	}
+	n := len(d)
+	if n == 0 {
+		return 1
+	}
+	sort.Slice(d, func(i, j int) bool { return math.Abs(d[i]) < math.Abs(d[j]) })
+	wPlus := 0.0
+	tie := 0.0
+	for i := 0; i < n; {
+		j := i
+		for j < n && math.Abs(d[j]) == math.Abs(d[i]) {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // i+1からjまでの平均
+		for k := i; k < j; k++ {
+			if d[k] > 0 {
+				wPlus += rank
+			}
+		}
+		t := float64(j - i)
+		tie += t*t*t - t
+		i = j
+	}
+	fn := float64(n)
+	mu := fn * (fn + 1) / 4
+	sigma := math.Sqrt(fn*(fn+1)*(2*fn+1)/24 - tie/48)
+	if sigma == 0 {
+		return 1
+	}
+	// 連続性の補正
+	z := math.Max(math.Abs(wPlus-mu)-0.5, 0) / sigma
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// ratioPercent は対数比を変化率(%)にします。
+func ratioPercent(r float64) float64 {
+	return (math.Exp(r) - 1) * 100
+}
+
+// printPaired は比較結果を表示します。compactの場合はスクリプトで扱いやすい1行にします。
+func printPaired(res pairedResult, capA, capB string, compact bool) {
+	if compact {
+		fmt.Printf("win=%d lose=%d tie=%d n=%d mean=%+.4f%% ci=%+.4f%%,%+.4f%% p=%.4g\n",
+			res.win, res.lose, res.tie, res.n, ratioPercent(res.mean), ratioPercent(res.lo), ratioPercent(res.hi), res.p)
+		return
+	}
+	fmt.Println("")
+	fmt.Printf("[Paired (%s -> %s)]\n", capA, capB)
+	fmt.Printf("Win %d  Lose %d  Tie %d", res.win, res.lose, res.tie)
+	if res.failA > 0 || res.failB > 0 {
+		fmt.Printf("  (failed only in %s: %d, only in %s: %d)", capA, res.failA, capB, res.failB)
+	}
+	fmt.Println("")
+	if res.n == 0 {
+		fmt.Println("No cases to compare")
+		return
+	}
+	fmt.Printf("Mean ratio %+.2f%%  95%% CI [%+.2f%%, %+.2f%%]  (%d cases)\n", ratioPercent(res.mean), ratioPercent(res.lo), ratioPercent(res.hi), res.n)
+	fmt.Printf("Wilcoxon p = %.4g\n", res.p)
+}
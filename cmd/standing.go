@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// competitor は公式の順位表(result.csv)の1行です。
+type competitor struct {
+	name   string
+	scores []caseScore
+}
+
+// standingRow は推定順位表の1行です。
+type standingRow struct {
+	rank  int
+	name  string
+	total float64
+	self  bool
+}
+
+// officialCompetitors は公式の順位表の参加者を読み込みます。自分の実行の行は含めません。
+func officialCompetitors() []competitor {
+	_, rows := resultCsvRows(false)
+	cs := make([]competitor, 0, len(rows))
+	for _, row := range rows {
+		if ownResultRow.MatchString(row) {
+			continue
+		}
+		ls := strings.Split(row, ",")
+		c := competitor{name: ls[0], scores: make([]caseScore, set.TestDataNum)}
+		for j := 0; j < set.TestDataNum && j+1 < len(ls); j++ {
+			c.scores[j] = parseResultField(ls[j+1])
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// estimateStanding はスコアscで参加した場合の公式の相対評価での順位表を返します。
+// ベストは参加者全員とscのケースごとのベストで、scの行はselfがtrueです。
+func estimateStanding(sc []caseScore, cs []competitor) []standingRow {
+	best := make([]caseScore, set.TestDataNum)
+	copy(best, sc)
+	for _, c := range cs {
+		for j := range best {
+			best[j] = updateBest(best[j], c.scores[j])
+		}
+	}
+	ids := make([]int, set.TestDataNum)
+	for i := range ids {
+		ids[i] = i
+	}
+	totals := make([]float64, 0, len(cs)+1)
+	for _, c := range cs {
+		totals = append(totals, relativeScore(c.scores, best, ids))
+	}
+	totals = append(totals, relativeScore(sc, best, ids))
+
+	idx, ranks := standingsRanks(totals)
+	rows := make([]standingRow, len(idx))
+	for k, i := range idx {
+		rows[k] = standingRow{rank: ranks[k], total: totals[i]}
+		if i == len(cs) {
+			rows[k].self = true
+		} else {
+			rows[k].name = cs[i].name
+		}
+	}
+	return rows
+}
+
+// printStanding はシステムテストのセットで、scの推定順位と前後の参加者を表示します。
+// 公式の順位表がない場合は何も表示しません。
+func printStanding(sc []caseScore, label string) {
+	if !set.IsSystemTest {
+		return
+	}
+	cs := officialCompetitors()
+	if len(cs) == 0 {
+		return
+	}
+	rows := estimateStanding(sc, cs)
+	pos := 0
+	for k := range rows {
+		if rows[k].self {
+			pos = k
+			break
+		}
+	}
+	headerStyle := lipgloss.NewStyle().Bold(true).Background(lipgloss.Color("8"))
+	fmt.Println("")
+	fmt.Println(headerStyle.Render("Estimated Standing"))
+	fmt.Printf("Rank %d / %d  Score %s (%.3f%%)\n", rows[pos].rank, len(rows), ftoa(rows[pos].total), rows[pos].total/(relEval()*float64(set.TestDataNum))*100)
+	self := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3"))
+	for k := max(pos-2, 0); k < min(pos+3, len(rows)); k++ {
+		line := fmt.Sprintf("%6d  %-20s %16s", rows[k].rank, truncString(rows[k].name, 20), ftoa(rows[k].total))
+		if rows[k].self {
+			line = self.Render(fmt.Sprintf("%6d  %-20s %16s", rows[k].rank, truncString(label, 20), ftoa(rows[k].total)))
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 実行結果の保存形式
+//
+//	runs.jsonl    : 1行に1回分の実行結果(runRecord)をJSONで追記する。schemaは形式のバージョン
+//	standings.csv : 公式の順位表データ(result.csvのうち自分の実行以外の行)。hc config systemで取得する
+//	result.csv    : 順位表ページ用にruns.jsonlとstandings.csvから書き出すファイル
+
+// StoreSchema はruns.jsonlの形式のバージョンです。
+const StoreSchema = 1
+
+// runRecord は1回分の実行結果です。
+type runRecord struct {
+	Schema   int          `json:"schema"`
+	No       int          `json:"no"` // ログ番号(-wなしの実行は-1)
+	Time     time.Time    `json:"time"`
+	Comment  string       `json:"comment"`
+	Complete bool         `json:"complete"`         // 中断されずに全ケースを実行したか
+	Trials   int          `json:"trials,omitempty"` // --loopの試行回数
+	Build    string       `json:"build,omitempty"`  // ビルドハッシュ
+	Git      *gitRecord   `json:"git,omitempty"`    // ソースの状態(ログ番号がある実行だけ記録する)
+	Summary  runSummary   `json:"summary"`
+	Cases    []caseRecord `json:"cases,omitempty"` // ログ番号がある実行だけ記録する
+}
+
+// runSummary は実行全体の集計です。
+type runSummary struct {
+	Cases int     `json:"cases"` // テストセットのケース数
+	OK    int     `json:"ok"`
+	NG    int     `json:"ng"`
+	TLE   int     `json:"tle"`
+	Sum   float64 `json:"sum"`
+	GM    float64 `json:"gm"`
+	AM    float64 `json:"am"`
+}
+
+// caseRecord は1ケース分の結果です。実行しなかったケースは記録しません。
+type caseRecord struct {
+	ID      int          `json:"id"`
+	Score   *float64     `json:"score,omitempty"` // スコアが得られなかった場合はなし
+	Verdict string       `json:"verdict,omitempty"`
+	Wall    int64        `json:"wall,omitempty"`  // ms
+	CPU     int64        `json:"cpu,omitempty"`   // ms
+	Mem     int64        `json:"mem,omitempty"`   // KB
+	JWall   int64        `json:"jwall,omitempty"` // ms
+	JCPU    int64        `json:"jcpu,omitempty"`  // ms
+	Trial   *trialRecord `json:"trial,omitempty"`
+}
+
+// trialRecord は--loopで複数回実行した場合のばらつきです。
+type trialRecord struct {
+	N   int     `json:"n"`
+	SD  float64 `json:"sd"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func runStorePath() string {
+	return fmt.Sprintf("%s/%s", logs.logDir, RunsJsonl)
+}
+
+// jsonLine は実行結果をruns.jsonlの1行に変換します。
+func jsonLine(rec runRecord) ([]byte, error) {
+	rec.Schema = StoreSchema
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// appendRunRecord は実行結果をruns.jsonlに1行追記します。
+func appendRunRecord(rec runRecord) error {
+	b, err := jsonLine(rec)
+	if err != nil {
+		return err
+	}
+	return writeToFile(runStorePath(), b, true)
+}
+
+// summarize はケースごとのスコアから集計を作成します。TLEは区別できないため失敗に含めます。
+func summarize(sc []caseScore) runSummary {
+	s := runSummary{Cases: len(sc)}
+	for _, c := range sc {
+		switch c.st {
+		case scoreOK:
+			s.OK++
+			s.Sum += c.v
+		case scoreFailed:
+			s.NG++
+		}
+	}
+	s.GM, s.AM, _ = calcAverage(sc)
+	return s
+}
+
+// readRunRecords はruns.jsonlを読み込みます。新しいバージョンの形式や壊れた行は読み飛ばします。
+func readRunRecords(path string) ([]runRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	recs := make([]runRecord, 0)
+	r := bufio.NewReader(f)
+	skipped := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var rec runRecord
+			if json.Unmarshal(line, &rec) != nil || rec.Schema > StoreSchema {
+				skipped++
+			} else {
+				recs = append(recs, rec)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return recs, err
+		}
+	}
+	if skipped > 0 {
+		warningPrint("Skipped %d unreadable or newer-format records in %s", skipped, path)
+	}
+	return recs, nil
+}
+
+// loadRunStore はruns.jsonlからログ番号のある実行を読み込み、履歴やベストを設定します。
+// runs.jsonlがなく旧形式のCSVがある場合は先に移行します。
+func loadRunStore() {
+	logs.best = make([]caseScore, set.TestDataNum)
+	logs.last = make([]caseScore, set.TestDataNum)
+	logs.stats = make(map[int][]caseStats)
+	logs.trials = make(map[int][]trialStat)
+	logs.builds = make(map[int]string)
+	logs.verdicts = make(map[int][]string)
+	logs.runs = nil
+	logs.vals, logs.idxes, logs.times, logs.comments = nil, nil, nil, nil
+	logs.isBlank = false
+
+	migrateCsvLogs()
+	recs, err := readRunRecords(runStorePath())
+	if err != nil {
+		warningPrint("Failed to read %s: %v", runStorePath(), err)
+	}
+	logged := make([]runRecord, 0, len(recs))
+	for _, rec := range recs {
+		if rec.No < 0 {
+			continue
+		}
+		logged = append(logged, rec)
+		logs.nextNo = max(logs.nextNo, rec.No+1)
+	}
+	if len(logged) > MaxHistoryRefSize {
+		logged = logged[len(logged)-MaxHistoryRefSize:]
+	}
+	if len(logged) == 0 {
+		logs.isBlank = true
+		return
+	}
+	logs.runs = logged
+	for _, rec := range logged {
+		sc := rec.scores()
+		logs.vals = append(logs.vals, sc)
+		logs.idxes = append(logs.idxes, rec.No)
+		logs.times = append(logs.times, rec.Time.Format("01/02 15:04:05"))
+		logs.comments = append(logs.comments, rec.Comment)
+		// 途中で中断した実行は順位表と同じくベストと前回の実行の対象にしない
+		if rec.Complete {
+			for j := range sc {
+				logs.best[j] = updateBest(logs.best[j], sc[j])
+			}
+			copy(logs.last, sc)
+		}
+		if rec.Build != "" {
+			logs.builds[rec.No] = rec.Build
+		}
+		rec.fillCaseDetails()
+	}
+}
+
+// findRun はログ番号noの実行を返します。見つからない場合はnilです。
+func findRun(no int) *runRecord {
+	for i := range logs.runs {
+		if logs.runs[i].No == no {
+			return &logs.runs[i]
+		}
+	}
+	return nil
+}
+
+// scores はテストセットのケース数に合わせたスコアを返します。範囲外のケースは無視します。
+func (rec runRecord) scores() []caseScore {
+	sc := make([]caseScore, set.TestDataNum)
+	for _, c := range rec.Cases {
+		if c.ID < 0 || c.ID >= set.TestDataNum {
+			continue
+		}
+		if c.Score == nil {
+			sc[c.ID] = caseScore{st: scoreFailed}
+		} else {
+			sc[c.ID] = okScore(*c.Score)
+		}
+	}
+	return sc
+}
+
+// fillCaseDetails は実行時間、判定結果、試行統計が記録されていればlogsに設定します。
+func (rec runRecord) fillCaseDetails() {
+	var st []caseStats
+	var vd []string
+	var tr []trialStat
+	for _, c := range rec.Cases {
+		if c.ID < 0 || c.ID >= set.TestDataNum {
+			continue
+		}
+		if c.Wall != 0 || c.Mem != 0 {
+			if st == nil {
+				st = make([]caseStats, set.TestDataNum)
+			}
+			st[c.ID] = caseStats{
+				wall:      time.Duration(c.Wall) * time.Millisecond,
+				cpu:       time.Duration(c.CPU) * time.Millisecond,
+				maxRSS:    c.Mem,
+				judgeWall: time.Duration(c.JWall) * time.Millisecond,
+				judgeCpu:  time.Duration(c.JCPU) * time.Millisecond,
+			}
+		}
+		if c.Verdict != "" {
+			if vd == nil {
+				vd = make([]string, set.TestDataNum)
+			}
+			vd[c.ID] = c.Verdict
+		}
+		if c.Trial != nil {
+			if tr == nil {
+				tr = make([]trialStat, set.TestDataNum)
+			}
+			t := trialStat{sd: c.Trial.SD, min: c.Trial.Min, max: c.Trial.Max, n: c.Trial.N}
+			if c.Score != nil {
+				t.mean = *c.Score
+			}
+			tr[c.ID] = t
+		}
+	}
+	if st != nil {
+		logs.stats[rec.No] = st
+	}
+	if vd != nil {
+		logs.verdicts[rec.No] = vd
+	}
+	if tr != nil {
+		logs.trials[rec.No] = tr
+	}
+}
+
+// newRunRecord は現在の実行結果から記録を作成します。caseDetailがtrueの場合はケースごとの結果も含めます。
+func newRunRecord(comment string, summary runSummary, caseDetail bool) runRecord {
+	rec := runRecord{
+		No:       -1,
+		Time:     time.Now(),
+		Comment:  comment,
+		Complete: !ri.interrupted,
+		Build:    ri.buildHash,
+		Summary:  summary,
+	}
+	if opt.loop > 1 {
+		rec.Trials = opt.loop
+	}
+	if !caseDetail {
+		return rec
+	}
+	rec.Cases = make([]caseRecord, 0, set.TestDataNum)
+	for i := 0; i < set.TestDataNum; i++ {
+		if !isCompleted(i) {
+			continue
+		}
+		c := caseRecord{ID: i, Verdict: caseVerdict(i)}
+		if ri.score[i].valid() {
+			v := ri.score[i].v
+			c.Score = &v
+		}
+		if st := ri.stats[i]; st.wall != 0 {
+			c.Wall, c.CPU, c.Mem = st.wall.Milliseconds(), st.cpu.Milliseconds(), st.maxRSS
+			c.JWall, c.JCPU = st.judgeWall.Milliseconds(), st.judgeCpu.Milliseconds()
+		}
+		if opt.loop > 1 {
+			ts := calcTrialStat(ri.trials[i])
+			c.Trial = &trialRecord{N: ts.n, SD: ts.sd, Min: ts.min, Max: ts.max}
+		}
+		rec.Cases = append(rec.Cases, c)
+	}
+	return rec
+}
+
+// resultCsvRows は順位表の行(ヘッダーを除く)を返します。
+// ownがtrueの場合は自分の実行を新しい順に、中断されなかったものだけを含め、その後に公式の順位表データを続けます。
+func resultCsvRows(own bool) (header string, rows []string) {
+	header = fmt.Sprintf("rank_min,%d,%s", sd.RelEval, sd.VisualizerURL)
+	official := readFileLines(fmt.Sprintf("%s/%s", logs.logDir, StandingsCsv))
+	if len(official) > 0 {
+		header, official = official[0], official[1:]
+	}
+	if own {
+		for i := len(logs.runs) - 1; i >= 0; i-- {
+			rec := logs.runs[i]
+			if !rec.Complete {
+				continue
+			}
+			// 順位表はカンマ区切りのため、コメントのカンマは空白に置き換える
+			comment := strings.ReplaceAll(rec.Comment, ",", " ")
+			rows = append(rows, fmt.Sprintf("%04d:%s,%s", rec.No, comment, resultsToCsv(rec.scores())))
+		}
+	}
+	rows = append(rows, official...)
+	return header, rows
+}
+
+// exportResultCsv は順位表ページ用のresult.csvを書き出します。
+func exportResultCsv(path string) error {
+	header, rows := resultCsvRows(true)
+	var sb strings.Builder
+	sb.WriteString(header + "\n")
+	for _, r := range rows {
+		sb.WriteString(r + "\n")
+	}
+	return writeToFile(path, []byte(sb.String()), false)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+)
+
+// trialResult は1回の実行結果をcaseScoreにします。
+func trialResult(res caseResult) caseScore {
+	if !res.ok {
+		return caseScore{st: scoreFailed}
+	}
+	return okScore(res.score)
+}
+
+// trialScore は複数回実行したスコアのうち有効なものの平均値を返します。有効なスコアがなければ失敗とします。
+func trialScore(a []caseScore) caseScore {
+	st := calcTrialStat(a)
+	if st.n == 0 {
+		if len(a) == 0 {
+			return caseScore{}
+		}
+		return caseScore{st: scoreFailed}
+	}
+	return okScore(st.mean)
+}
+
+// calcTrialStat は有効なスコアの平均、標準偏差、最小値、最大値を求めます。
+func calcTrialStat(a []caseScore) trialStat {
+	st := trialStat{}
+	sum := 0.0
+	for _, s := range a {
+		if !s.valid() {
+			continue
+		}
+		v := s.v
+		if st.n == 0 || v < st.min {
+			st.min = v
+		}
+		if st.n == 0 || v > st.max {
+			st.max = v
+		}
+		st.n++
+		sum += v
+	}
+	if st.n == 0 {
+		return st
+	}
+	st.mean = sum / float64(st.n)
+	if st.n > 1 {
+		ss := 0.0
+		for _, s := range a {
+			if s.valid() {
+				ss += (s.v - st.mean) * (s.v - st.mean)
+			}
+		}
+		st.sd = math.Sqrt(ss / float64(st.n-1))
+	}
+	return st
+}
+
+// trialSeed はケースと試行番号ごとに異なるシード値を返します(環境変数HC_SEEDでソルバーに渡す)。
+func trialSeed(idx, trial int) int64 {
+	// splitmix64で値を散らし、扱いやすいように31ビットの正の値にする
+	x := uint64(idx)<<32 | uint64(trial)
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return int64(x & 0x7fffffff)
+}
+
+// trialSummary は--loopで実行した結果のばらつきを1行にまとめます。
+func trialSummary() string {
+	cvSum := 0.0
+	cnt := 0
+	for i := 0; i < len(ri.trials); i++ {
+		st := calcTrialStat(ri.trials[i])
+		if st.n < 2 || st.mean == 0 {
+			continue
+		}
+		cvSum += st.sd / math.Abs(st.mean)
+		cnt++
+	}
+	if cnt == 0 {
+		return fmt.Sprintf("Trials: %d per case", opt.loop)
+	}
+	return fmt.Sprintf("Trials: %d per case  Mean CV: %.2f%%", opt.loop, cvSum/float64(cnt)*100)
+}
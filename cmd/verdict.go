@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// ケースの判定結果
+const (
+	VerdictAC      = "AC"  // スコアが得られた
+	VerdictWA      = "WA"  // ジャッジが出力を受け付けなかった
+	VerdictRE      = "RE"  // プログラムが異常終了した
+	VerdictTLE     = "TLE" // 制限時間を超えた
+	VerdictMLE     = "MLE" // メモリ制限を超えた
+	VerdictNoScore = "NS"  // スコアが見つからなかった
+)
+
+// verdictOrder は判定結果を集計して表示する順序です。
+var verdictOrder = []string{VerdictAC, VerdictWA, VerdictRE, VerdictTLE, VerdictMLE, VerdictNoScore}
+
+var (
+	waPatternOnce sync.Once
+	waPatternRe   *regexp.Regexp
+)
+
+// memoryLimitKB はメモリ制限(KB)を返します。優先順位は --memory-limit、[common] の順で、0は制限なしです。
+func memoryLimitKB() int64 {
+	ml := cmn.MemoryLimit
+	if opt.memoryLimit > 0 {
+		ml = opt.memoryLimit
+	}
+	return int64(ml) * 1024
+}
+
+// judgeRejected はジャッジの出力が[score]のWAPatternに一致するかを返します。
+func judgeRejected(judgeOut string) bool {
+	if scr.WAPattern == "" {
+		return false
+	}
+	waPatternOnce.Do(func() {
+		re, err := regexp.Compile(scr.WAPattern)
+		if err != nil {
+			warningPrint("Invalid WAPattern '%s': %v", scr.WAPattern, err)
+			return
+		}
+		waPatternRe = re
+	})
+	return waPatternRe != nil && waPatternRe.MatchString(judgeOut)
+}
+
+// judgeVerdict は1ケースの実行結果から判定結果を決めます。
+// solverErrはプログラムの終了状態で、ジャッジがプログラムを起動する場合は区別できないためnilを渡します。
+// スコアを終了コードから読み取る場合、ジャッジの終了コードはWAの判定に使いません。
+func judgeVerdict(stats caseStats, solverErr error, judgeErr error, judgeOut string, scoreErr error) string {
+	if errors.Is(solverErr, errTimeLimitExceeded) || errors.Is(judgeErr, errTimeLimitExceeded) {
+		return VerdictTLE
+	}
+	if ml := memoryLimitKB(); ml > 0 && stats.maxRSS > ml {
+		return VerdictMLE
+	}
+	if solverErr != nil {
+		return VerdictRE
+	}
+	if judgeRejected(judgeOut) {
+		return VerdictWA
+	}
+	if scoreErr != nil {
+		if judgeErr != nil && scoreSource() != ScoreSourceExitCode {
+			return VerdictWA
+		}
+		return VerdictNoScore
+	}
+	return VerdictAC
+}
+
+// exitDetail は異常終了の内容(終了コードやシグナル)を返します。
+func exitDetail(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ProcessState.String()
+	}
+	return err.Error()
+}
+
+// caseVerdict はケースiの判定結果を返します。記録がない場合はスコアとTLEから決めます。
+func caseVerdict(i int) string {
+	if i < len(ri.verdict) && ri.verdict[i] != "" {
+		return ri.verdict[i]
+	}
+	switch {
+	case ri.score[i].valid():
+		return VerdictAC
+	case ri.tle[i]:
+		return VerdictTLE
+	}
+	return VerdictNoScore
+}
+
+// verdictSummary は判定結果ごとのケース数を「AC 10  WA 0 ...」の形式で返します。
+func verdictSummary(counts map[string]int) string {
+	s := make([]string, 0, len(verdictOrder))
+	for _, v := range verdictOrder {
+		s = append(s, fmt.Sprintf("%s %d", v, counts[v]))
+	}
+	return strings.Join(s, "  ")
+}
+
+// parseVerdictFilter は「RE,TLE」のようなカンマ区切りの判定結果を集合にします。
+func parseVerdictFilter(s string) (map[string]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if !isVerdict(v) {
+			return nil, fmt.Errorf("unknown verdict '%s' (%s)", v, strings.Join(verdictOrder, ", "))
+		}
+		m[v] = true
+	}
+	return m, nil
+}
+
+func isVerdict(v string) bool {
+	return slices.Contains(verdictOrder, v)
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// watchCmd はソースファイルの変更を監視し、変更のたびにビルドしてテストセットを実行します。
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Rebuild and rerun the test set whenever the source files change",
+	Long:  `Rebuild and rerun the test set whenever the source files change`,
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		if len(strings.Fields(cmn.SourceFiles)) == 0 {
+			errorPrint("Set SourceFiles in the [common] section to use hc watch")
+			return
+		}
+		if opt.watchInterval <= 0 {
+			opt.watchInterval = 500
+		}
+		// 実行中の表示は1行の結果にまとめる
+		opt.quietMode = true
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		watchLoop(ctx)
+	},
+}
+
+// watchLoop はソースファイルのハッシュを定期的に確認し、変わったら実行中のテストを中断して新しく実行します。
+// 保存が続いている間は待ち、ハッシュが1周期変わらなくなってから実行します。
+func watchLoop(ctx context.Context) {
+	testID := ri.testID
+	// 前回の実行との比較には最初は履歴の最後の実行を使い、以降はhc watchでの前回の実行を使う
+	prev := make([]caseScore, set.TestDataNum)
+	copy(prev, logs.last)
+
+	hash, err := calcBuildHash()
+	if err != nil {
+		errorPrint("%v", err)
+		return
+	}
+	ticker := time.NewTicker(time.Duration(opt.watchInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	var cancel context.CancelFunc
+	var done chan bool
+	var started time.Time
+	cnt := 0
+	pending := true
+	fmt.Printf("Watching %s (Ctrl-C to stop)\n", cmn.SourceFiles)
+	for {
+		select {
+		case <-ctx.Done():
+			if cancel != nil {
+				cancel()
+				<-done
+			}
+			fmt.Println("")
+			return
+		case completed := <-done:
+			cancel()
+			cancel = nil
+			done = nil
+			if completed {
+				printWatchSummary(cnt, prev, time.Since(started))
+				for _, i := range testID {
+					prev[i] = ri.score[i]
+				}
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		h, err := calcBuildHash()
+		if err != nil {
+			continue
+		}
+		if h != hash {
+			hash = h
+			pending = true
+			continue
+		}
+		if !pending {
+			continue
+		}
+		pending = false
+
+		// 新しい変更があれば実行中のテストは最後まで待たずに中断する
+		if cancel != nil {
+			cancel()
+			<-done
+			cancel = nil
+			done = nil
+			warningPrint("#%d canceled (source changed)", cnt)
+		}
+		cnt++
+		fmt.Printf("#%d %s building and running\n", cnt, time.Now().Format("15:04:05"))
+		buildHash, ok := autoBuild()
+		if !ok {
+			fmt.Println("Waiting for changes...")
+			continue
+		}
+		if buildHash == "" {
+			buildHash = hash
+		}
+
+		var runCtx context.Context
+		runCtx, cancel = context.WithCancel(ctx)
+		done = make(chan bool, 1)
+		started = time.Now()
+		go func(ch chan bool) {
+			ch <- watchRun(runCtx, testID, buildHash)
+		}(done)
+	}
+}
+
+// watchRun はtestIDのケースを実行し、最後まで実行できたかを返します。履歴には記録しません。
+func watchRun(ctx context.Context, testID []int, buildHash string) bool {
+	ri = RuntimeInfo{testID: testID, parentCtx: ctx, buildHash: buildHash}
+	runtimeInit()
+	ri.enableLog = false
+	ri.enableLogStandings = false
+	if len(ri.testID) > 0 {
+		workerPool()
+	}
+	return !ri.interrupted
+}
+
+// watchDelta は基準refに対する今回の実行結果の比較です。
+type watchDelta struct {
+	gmRatio  float64
+	hasGm    bool
+	better   int
+	worse    int
+	same     int
+	worstId  int     // 最も悪化したケース(なければ-1)
+	worstVal float64 // そのケースの変化率(%)
+}
+
+// calcWatchDelta は実行したケースについて基準refと比較します。
+// GMの変化率は両方で正のスコアが得られたケースだけで計算します。
+func calcWatchDelta(ref []caseScore) watchDelta {
+	d := watchDelta{worstId: -1}
+	logSum, refLogSum, n := 0.0, 0.0, 0
+	for _, i := range ri.testID {
+		cur := ri.score[i]
+		switch {
+		case !cur.valid() && !ref[i].valid():
+			d.same++
+			continue
+		case !ref[i].valid():
+			d.better++
+			continue
+		case !cur.valid():
+			d.worse++
+			continue
+		case isBetter(cur.v, ref[i].v):
+			d.better++
+		case isBetter(ref[i].v, cur.v):
+			d.worse++
+		default:
+			d.same++
+		}
+		// 悪化の大きさはIsRankMinを考慮して比べ、表示は実際の変化率にする
+		if f, ok := scoreRatio(cur.v, ref[i]); ok && isBetter(ref[i].v, cur.v) {
+			if d.worstId == -1 || math.Abs(f) > math.Abs(d.worstVal) {
+				d.worstId, d.worstVal = i, f
+			}
+		}
+		if cur.v > 0 && ref[i].v > 0 {
+			logSum += math.Log(cur.v)
+			refLogSum += math.Log(ref[i].v)
+			n++
+		}
+	}
+	if n > 0 {
+		d.gmRatio = (math.Exp((logSum-refLogSum)/float64(n)) - 1) * 100
+		d.hasGm = true
+	}
+	return d
+}
+
+// printWatchSummary は実行結果と、前回の実行・ベストとの比較を数行で表示します。
+func printWatchSummary(cnt int, prev []caseScore, elapsed time.Duration) {
+	scores := make([]caseScore, 0, len(ri.testID))
+	ng, tle := 0, 0
+	for _, i := range ri.testID {
+		scores = append(scores, ri.score[i])
+		if !ri.score[i].valid() {
+			if ri.tle[i] {
+				tle++
+			} else {
+				ng++
+			}
+		}
+	}
+	gm, am, _ := calcAverage(scores)
+	bold := lipgloss.NewStyle().Bold(true)
+	fmt.Printf("#%d %s %s  GM %s  AM %s  NG %d  TLE %d  (%.1fs)\n", cnt, time.Now().Format("15:04:05"), ri.buildHash,
+		bold.Render(formatMean(gm)), formatMean(am), ng, tle, elapsed.Seconds())
+
+	best := logs.best
+	if set.IsSystemTest {
+		best = logs.best2
+	}
+	green := lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	red := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	for _, r := range []struct {
+		caption string
+		ref     []caseScore
+	}{{"Prev", prev}, {"Best", best}} {
+		d := calcWatchDelta(r.ref)
+		gmText := "-"
+		if d.hasGm {
+			gmText = fmt.Sprintf("%+.2f%%", d.gmRatio)
+			if (d.gmRatio > 0) != cmn.IsRankMin && d.gmRatio != 0 {
+				gmText = green.Render(gmText)
+			} else if d.gmRatio != 0 {
+				gmText = red.Render(gmText)
+			}
+		}
+		line := fmt.Sprintf("   %s  GM %s  better %d  worse %d  same %d", r.caption, gmText, d.better, d.worse, d.same)
+		if d.worstId != -1 {
+			line += fmt.Sprintf("  worst [%04d] %s", d.worstId, red.Render(fmt.Sprintf("%.2f%%", d.worstVal)))
+		}
+		fmt.Println(line)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	watchCmd.Flags().StringVarP(&opt.filter, "filter", "f", "", "Set filter definition")
+	watchCmd.Flags().IntVar(&opt.watchInterval, "interval", 500, "Interval in milliseconds to check the source files")
+	watchCmd.Flags().BoolVarP(&opt.debugMode, "debug", "x", false, "Enable debug output")
+}
@@ -3,14 +3,11 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/skratchdot/open-golang/open"
 	"github.com/spf13/cobra"
 	"html/template"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
-	"time"
 )
 
 // webCmd represents the web command
@@ -54,55 +51,47 @@ func noCache(next http.Handler) http.Handler {
 	})
 }
 func showStandings() {
-	t := fmt.Sprintf("%s", logs.logRootDir)
-	if !changeDir(t) {
-		return
+	resultCsv := fmt.Sprintf("%s/%s", logs.logDir, ResultCsv)
+	if err := exportResultCsv(resultCsv); err != nil {
+		warningPrint("Failed to write %s: %v", resultCsv, err)
 	}
-	go func() {
-		//サーバーが起動するのを少し待つ
-		var contestType string
-		if conf.Common.IsRankMin == true {
-			contestType = "rank_min"
-		} else {
-			contestType = "rank_max"
-		}
-		url := fmt.Sprintf("http://localhost:8080/?contest=%s&contest_type=%s", set.SetName, contestType)
-		time.Sleep(1 * time.Second)
-		// ブラウザを開く
-		err := open.Start(url)
-		if err != nil {
-			log.Fatal("Failed to open browser: ", err)
-		}
-	}()
-
-	// httpサーバーを起動する
-	fs := http.FileServer(http.Dir("."))
-	http.Handle("/", noCache(fs))
-	log.Println("Serving on :8080")
-	err := http.ListenAndServe(":8080", nil)
-	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
-		return
+	var contestType string
+	if conf.Common.IsRankMin == true {
+		contestType = "rank_min"
+	} else {
+		contestType = "rank_max"
 	}
+
+	// 順位表のページは埋め込んだものを返し、result.csvとinput.csvはlogsディレクトリから返す
+	mux := http.NewServeMux()
+	mux.Handle("/assets/", assetHandler())
+	files := noCache(http.FileServer(http.Dir(logs.logRootDir)))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+			page, err := assetFS.ReadFile("assets/standings.html")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(page)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+	serveWeb(mux, fmt.Sprintf("/?contest=%s&contest_type=%s", set.SetName, contestType))
 }
 func showHistogram() {
-	go func() {
-		//サーバーが起動するのを少し待つ
-		time.Sleep(1 * time.Second)
-		// ブラウザを開く
-		err := open.Start("http://localhost:8080")
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to open browser: ", err)
-		}
-	}()
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.Handle("/assets/", assetHandler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// ヒストグラムデータをJSON形式に変換
 		dataJson, err := json.Marshal(getHistgramData())
 		if err != nil {
 			log.Fatalf("JSON Marshal error: %v", err)
 		}
 		// テンプレートにデータを渡してレンダリング
-		err = tmpl.Execute(w, map[string]interface{}{
+		err = paramTmpl.Execute(w, map[string]interface{}{
 			"Title":      fmt.Sprintf("%s", cmn.ContestName),
 			"DataHeader": hi.Header,
 			"DataJson":   template.JS(dataJson),
@@ -111,11 +100,7 @@ func showHistogram() {
 			log.Fatalf("Template execution error: %v", err)
 		}
 	})
-	log.Println("Server started on :8080")
-	err := http.ListenAndServe(":8080", nil)
-	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
-	}
+	serveWeb(mux, "/")
 }
 
 func getHistgramData() map[string][]float64 {
@@ -129,58 +114,12 @@ func getHistgramData() map[string][]float64 {
 	return ret
 }
 
-// ヒストグラム表示用のHTMLテンプレート
-var tmpl = template.Must(template.New("histogram").Parse(`
-<!DOCTYPE html>
-<html>
-<head>
-    <title>{{.Title}}</title>
-    <script src="https://cdn.plot.ly/plotly-latest.min.js"></script>
-</head>
-<body>
-
-<!-- データの種類を選択するドロップダウンメニュー -->
-<select id="dataSelector" onchange="updatePlot()">
-    {{range $_, $key := .DataHeader}}
-    <option value="{{$key}}">パラメータ {{$key}}</option>
-    {{end}}
-</select>
-
-<div id="plot"></div>
-
-<script>
-var allData = {{.DataJson}};
-
-function updatePlot() {
-    var selectedType = document.getElementById('dataSelector').value;
-    var data = allData[selectedType];
-
-    var trace = {
-        x: data,
-        type: 'histogram',
-    };
-
-    var layout = {
-        title: {{.Title}},
-        xaxis: {title: 'Value'},
-        yaxis: {title: 'Count'}
-    };
-
-    Plotly.newPlot('plot', [trace], layout);
-}
-
-// 初期描画
-updatePlot();
-</script>
-
-</body>
-</html>
-`))
-
 func init() {
 	rootCmd.AddCommand(webCmd)
 	webCmd.AddCommand(webStandingsCmd)
 	webCmd.AddCommand(webParamCmd)
 	webStandingsCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
 	webParamCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+	webCmd.PersistentFlags().IntVar(&opt.webPort, "port", DefaultWebPort, "Port of the web server (a free port is used if it is in use)")
+	webCmd.PersistentFlags().StringVar(&opt.webHost, "host", "", "Host address of the web server (empty: all interfaces)")
 }
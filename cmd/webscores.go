@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Knetic/govaluate"
+	"github.com/spf13/cobra"
+)
+
+// webScoresCmd represents the scores subcommand of web
+var webScoresCmd = &cobra.Command{
+	Use:   "scores",
+	Short: "Displays the scores of the logged runs against the parameters.",
+	Long: `Displays the scores of the logged runs against the parameters.
+The scatter page plots the score (or the ratio to another run or the best) of each case against a parameter, and the heatmap shows the mean ratio for two parameters.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		commonInit()
+		showScores()
+	},
+}
+
+// scoresRun はページで選択できる実行です。
+type scoresRun struct {
+	No      int    `json:"no"`
+	Time    string `json:"time"`
+	Comment string `json:"comment"`
+}
+
+// scoresCase は1ケースの点です。Ratioは基準に対する比(1より大きいと良い)で、どちらかが失敗している場合などはnullです。
+type scoresCase struct {
+	ID     int       `json:"id"`
+	Params []float64 `json:"params"`
+	Score  *float64  `json:"score"`
+	Ratio  *float64  `json:"ratio"`
+}
+
+// scoresHeatmap は2つのパラメータのビンごとの比の平均(幾何平均)です。
+type scoresHeatmap struct {
+	X     []string     `json:"x"`
+	Y     []string     `json:"y"`
+	Z     [][]*float64 `json:"z"`
+	Count [][]int      `json:"count"`
+}
+
+// scoresData は/api/scoresの応答です。
+type scoresData struct {
+	Title   string         `json:"title"`
+	Params  []string       `json:"params"`
+	Runs    []scoresRun    `json:"runs"`
+	Run     int            `json:"run"`
+	Ref     string         `json:"ref"`
+	Cases   []scoresCase   `json:"cases"`
+	Heatmap *scoresHeatmap `json:"heatmap"`
+}
+
+// scoresMu はリクエストごとにログを読み直すときにグローバルな状態を守ります。
+var scoresMu sync.Mutex
+
+// getScoresData はクエリの実行(run)、基準(ref: 実行の番号またはbest)、フィルタ(filter)、ヒートマップのパラメータ(x, y, bins)からデータを作ります。
+func getScoresData(q map[string][]string) (*scoresData, error) {
+	get := func(k string) string {
+		if v, ok := q[k]; ok && len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	scoresMu.Lock()
+	defer scoresMu.Unlock()
+
+	// 実行中に追加されたログも表示できるよう毎回読み直す
+	loadLogs()
+	if len(logs.vals) == 0 {
+		return nil, fmt.Errorf("no logged runs")
+	}
+	filter := get("filter")
+	if filter != "" {
+		if _, err := govaluate.NewEvaluableExpression(filter); err != nil {
+			return nil, fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	d := &scoresData{Title: fmt.Sprintf("%s (%s)", cmn.ContestName, set.SetName), Params: hi.Header}
+	for i := len(logs.vals) - 1; i >= 0; i-- {
+		d.Runs = append(d.Runs, scoresRun{No: logs.idxes[i], Time: logs.times[i], Comment: logs.comments[i]})
+	}
+	runAt := func(s string) (int, error) {
+		no, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid log number: %s", s)
+		}
+		for i := range logs.idxes {
+			if logs.idxes[i] == no {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("log not found: %s", s)
+	}
+
+	pos := len(logs.vals) - 1
+	if s := get("run"); s != "" {
+		p, err := runAt(s)
+		if err != nil {
+			return nil, err
+		}
+		pos = p
+	}
+	d.Run = logs.idxes[pos]
+	cur := logs.vals[pos]
+	ref := logs.best
+	d.Ref = "best"
+	if s := get("ref"); s != "" && s != "best" {
+		p, err := runAt(s)
+		if err != nil {
+			return nil, err
+		}
+		ref = logs.vals[p]
+		d.Ref = s
+	}
+
+	ids := filteredIDsBy(filter)
+	for _, i := range ids {
+		c := scoresCase{ID: i, Params: make([]float64, len(hi.Header))}
+		for j := range hi.Header {
+			c.Params[j], _ = paramValue(i, j)
+		}
+		if cur[i].valid() {
+			v := cur[i].v
+			c.Score = &v
+		}
+		if r, ok := logRatio(cur[i], ref[i]); ok {
+			v := math.Exp(r)
+			c.Ratio = &v
+		}
+		d.Cases = append(d.Cases, c)
+	}
+
+	if get("x") != "" && get("y") != "" {
+		bins, err := strconv.Atoi(get("bins"))
+		if err != nil || bins <= 0 {
+			bins = 4
+		}
+		hm, err := scoresHeatmapOf(ids, get("x"), get("y"), bins, cur, ref)
+		if err != nil {
+			return nil, err
+		}
+		d.Heatmap = hm
+	}
+	return d, nil
+}
+
+// scoresHeatmapOf はパラメータxとyのビン(ケース数が等しくなるように分ける)ごとに基準に対する比の幾何平均を求めます。
+func scoresHeatmapOf(ids []int, x, y string, bins int, cur, ref []caseScore) (*scoresHeatmap, error) {
+	var bs [2][]caseBucket
+	for k, name := range []string{x, y} {
+		col, err := paramColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		if bs[k], err = binCases(ids, col, name, bins, false); err != nil {
+			return nil, err
+		}
+	}
+	hm := &scoresHeatmap{}
+	at := make(map[int][2]int)
+	for k := range bs {
+		for j, b := range bs[k] {
+			for _, id := range b.ids {
+				p := at[id]
+				p[k] = j
+				at[id] = p
+			}
+		}
+	}
+	for _, b := range bs[0] {
+		hm.X = append(hm.X, b.label)
+	}
+	sum := make([][]float64, len(bs[1]))
+	hm.Count = make([][]int, len(bs[1]))
+	hm.Z = make([][]*float64, len(bs[1]))
+	for j, b := range bs[1] {
+		hm.Y = append(hm.Y, b.label)
+		sum[j] = make([]float64, len(bs[0]))
+		hm.Count[j] = make([]int, len(bs[0]))
+		hm.Z[j] = make([]*float64, len(bs[0]))
+	}
+	for _, id := range ids {
+		if r, ok := logRatio(cur[id], ref[id]); ok {
+			p := at[id]
+			sum[p[1]][p[0]] += r
+			hm.Count[p[1]][p[0]]++
+		}
+	}
+	for j := range sum {
+		for i := range sum[j] {
+			if hm.Count[j][i] > 0 {
+				v := math.Exp(sum[j][i] / float64(hm.Count[j][i]))
+				hm.Z[j][i] = &v
+			}
+		}
+	}
+	return hm, nil
+}
+
+func showScores() {
+	mux := http.NewServeMux()
+	mux.Handle("/assets/", assetHandler())
+	mux.HandleFunc("/api/scores", func(w http.ResponseWriter, r *http.Request) {
+		d, err := getScoresData(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(d); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		err := scoresTmpl.Execute(w, map[string]interface{}{
+			"Title":      fmt.Sprintf("%s", cmn.ContestName),
+			"DataHeader": hi.Header,
+		})
+		if err != nil {
+			log.Printf("Template execution error: %v", err)
+		}
+	})
+	serveWeb(mux, "/")
+}
+
+func init() {
+	webCmd.AddCommand(webScoresCmd)
+	webScoresCmd.Flags().StringVarP(&opt.setName, "set-name", "s", "default", "Set name to run")
+}